@@ -4,9 +4,9 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"bytes"
+	"fmt"
 	"os"
-	"text/template"
+	"strings"
 	"zhaojunlucky/zgit/core"
 
 	log "github.com/sirupsen/logrus"
@@ -31,103 +31,197 @@ Example:
   It will execute:
     git commit -m "[JIRA-1234] fix bug"
 
+  All message sources of git commit are supported: multiple -m paragraphs,
+  -mmsg, --message=msg, -F/--file, -c/-C and the editor. The template is
+  applied to the subject line only and body paragraphs are kept:
+    zgit commit -m "fix bug" -m "details in the body"
+    zgit commit -F message.txt
+    zgit commit              # opens the editor with the ticket prefilled
+
+  A message that already has the ticket is left unchanged, so amends and
+  retries are not prefixed twice:
+    zgit commit --amend -m "[JIRA-1234] fix bug"
+
+  Use --ticket to set the ticket when the branch name has none or another one
+  is needed, otherwise the ticket.fallback sources of the config are tried:
+    zgit commit --ticket JIRA-99 -m "hotfix"
+    zgit commit --ticket JIRA-12,JIRA-13 -m "fix both"
+
+  With conventional commits enabled in the config, the type and scope are
+  taken from --type and --scope, from the message or picked interactively:
+    zgit commit --type feat --scope api -m "add endpoint"
+
+  Trailers configured in commit.trailers are appended, and --co-author adds a
+  Co-authored-by trailer for an alias of the coauthors config:
+    zgit commit --co-author alice -m "pair on login"
+
+  Use --no-ticket to commit without the ticket once:
+    zgit commit --no-ticket -m "fix bug"
+
   You can also pass other git flags:
     zgit commit --amend
     zgit commit -m "fix bug" --no-verify`,
 	DisableFlagParsing: true,
 	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Parse args manually to find -m flag
-		var message string
-		var messageIndex int = -1
-		var otherArgs []string
-		
-		for i := 0; i < len(args); i++ {
-			if args[i] == "-m" || args[i] == "--message" {
-				if i+1 < len(args) {
-					message = args[i+1]
-					messageIndex = i
-					i++ // Skip the message value
-				}
-			} else if messageIndex == -1 || i < messageIndex || i > messageIndex+1 {
-				otherArgs = append(otherArgs, args[i])
-			}
+		commitArgs, err := core.ParseCommitArgs(args)
+		if err != nil {
+			log.Fatalf("failed to parse commit arguments: %v", err)
 		}
-		
-		// If -m flag is not provided, pass all args directly to git commit
-		if messageIndex == -1 {
-			log.Info("no -m flag provided, calling git commit directly with args")
-			gitArgs := append([]string{"commit"}, args...)
-			if err := core.RunGitCommand(gitArgs...); err != nil {
-				log.Fatalf("failed to commit: %v", err)
-			}
-			log.Info("commit successful")
+
+		// Skip the ticket for this commit, the hooks must not add it either
+		if commitArgs.NoTicket {
+			log.Info("--no-ticket provided, calling git commit directly with args")
+			os.Setenv(core.SkipHooksEnv, "1")
+			runGitCommit(commitArgs.GitArgs())
+			return
+		}
+
+		// git builds the message of fixup and squash commits itself
+		if commitArgs.Fixup {
+			log.Info("fixup or squash commit, calling git commit directly with args")
+			runGitCommit(commitArgs.GitArgs())
 			return
 		}
-		
-		log.Infof("commit called with message: %s", message)
-		
+
+		text, edit, err := commitArgs.Message()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("commit called with message: %s", text)
+
 		pwd, err := os.Getwd()
 		if err != nil {
 			log.Fatal(err)
 		}
 		log.Infof("current working directory: %s", pwd)
 
-		// Check if current directory is a git repo and get repo full name
-		repoFullName, err := core.GetRepoFullName()
+		commitCtx, err := core.LoadCommitContext(ticketOptions(commitArgs.Tickets))
 		if err != nil {
-			log.Fatalf("failed to get repository name: %v", err)
+			log.Fatal(err)
 		}
-		log.Infof("repository: %s", repoFullName)
 
-		// Match branch
-		branch, err := core.GetCurrentBranch()
+		// The active pair of zgit pair is credited along with --co-author
+		coAuthors := append(core.GetPair(), commitArgs.CoAuthors...)
+		commitCtx.CoAuthors, err = commitCtx.Match.Settings.ResolveCoAuthors(coAuthors)
 		if err != nil {
-			log.Fatalf("failed to get current branch: %v", err)
+			log.Fatal(err)
 		}
-		log.Infof("branch: %s", branch)
 
-		// Match config
-		config, err := core.LoadConfig()
-		if err != nil {
-			log.Fatalf("failed to load config: %v", err)
+		// The template applies to the subject only, body paragraphs are kept
+		message := core.ParseCommitMessage(text, edit)
+		if err := chooseConventionalHeader(commitCtx, commitArgs, message); err != nil {
+			log.Fatal(err)
 		}
-		log.Infof("config: %v", config)
 
-		// Match branch
-		ticket, err := config.MatchBranch(repoFullName, branch)
+		emptyMessage, err := commitCtx.Render("")
 		if err != nil {
-			log.Fatalf("failed to match branch: %v", err)
+			log.Fatalf("failed to render commit message template: %v", err)
 		}
-		log.Infof("found ticket: %s from branch %s", ticket, branch)
 
-		// Render commit message template
-		tmpl, err := template.New("commit").Parse(config.Global.Commit.Message)
+		// The message is passed to git as a file kept in the git directory
+		messagePath, err := core.GetGitPath("ZGIT_EDITMSG")
 		if err != nil {
-			log.Fatalf("failed to parse commit message template: %v", err)
+			log.Fatalf("failed to locate git directory: %v", err)
 		}
 
-		var buf bytes.Buffer
-		data := map[string]string{
-			"Ticket":  ticket,
-			"Message": message,
+		if edit {
+			if message.IsEmpty() {
+				// Prefill the ticket so the user types the message after it
+				message.SetSubject(emptyMessage)
+			} else if err := commitCtx.Apply(message); err != nil {
+				log.Fatalf("failed to render commit message template: %v", err)
+			}
+			if err := message.Edit(messagePath); err != nil {
+				log.Fatalf("failed to edit commit message: %v", err)
+			}
+			switch commitArgs.Cleanup {
+			case "", "default", "strip":
+				message.StripComments()
+			}
 		}
-		if err := tmpl.Execute(&buf, data); err != nil {
+		if err := commitCtx.Apply(message); err != nil {
 			log.Fatalf("failed to render commit message template: %v", err)
 		}
-		commitMessage := buf.String()
+		if subject := strings.TrimSpace(message.Subject()); subject == "" || subject == strings.TrimSpace(emptyMessage) {
+			log.Fatal("aborting commit due to empty commit message")
+		}
+		if err := commitCtx.ApplyTrailers(message); err != nil {
+			log.Fatal(err)
+		}
+		commitMessage := message.String()
 		log.Infof("rendered commit message: %s", commitMessage)
+		if commitCtx.Match.Settings.Lint.IsEnforced() {
+			if err := lintCommitMessage(commitCtx, commitMessage); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err := os.WriteFile(messagePath, []byte(commitMessage), 0644); err != nil {
+			log.Fatalf("failed to write commit message: %v", err)
+		}
+		authorArgs, err := commitArgs.AuthorArgs()
+		if err != nil {
+			log.Fatal(err)
+		}
 
-		// Execute git commit with the formatted message and any additional args
-		gitArgs := []string{"commit", "-m", commitMessage}
-		gitArgs = append(gitArgs, otherArgs...)
-		if err := core.RunGitCommand(gitArgs...); err != nil {
-			log.Fatalf("failed to commit: %v", err)
+		// Execute git commit with the formatted message and any additional args,
+		// the message is final so the zgit hooks must not touch it again
+		os.Setenv(core.SkipHooksEnv, "1")
+		gitArgs := []string{"-F", messagePath}
+		gitArgs = append(gitArgs, authorArgs...)
+		gitArgs = append(gitArgs, commitArgs.Other...)
+		if err := core.RunGitCommand(append([]string{"commit"}, gitArgs...)...); err != nil {
+			log.Fatalf("failed to commit, the message is saved in %s: %v", messagePath, err)
 		}
+		_ = os.Remove(messagePath)
 		log.Info("commit successful")
+		if err := core.RememberTicket(commitCtx.Match, commitCtx.Branch); err != nil {
+			log.Warn(err)
+		}
 	},
 }
 
+// chooseConventionalHeader sets the Conventional Commits type and scope from
+// --type and --scope, the message header or the picker, in that order
+func chooseConventionalHeader(commitCtx *core.CommitContext, commitArgs *core.CommitArgs, message *core.CommitMessage) error {
+	conventional := commitCtx.Match.Settings.Conventional
+	if !conventional.IsEnabled() {
+		return nil
+	}
+	commitCtx.Header = core.ConventionalHeader{Type: commitArgs.Type, Scope: commitArgs.Scope}
+	if err := commitCtx.TakeConventionalHeader(message); err != nil {
+		return fmt.Errorf("failed to render commit message template: %w", err)
+	}
+
+	files, _ := core.GetStagedFiles()
+	suggestedScope := commitCtx.Config.SuggestScope(conventional, files)
+	switch {
+	case commitCtx.Header.Type == "" && isInteractive():
+		header, err := pickConventionalHeader(conventional, suggestedScope)
+		if err != nil {
+			return err
+		}
+		commitCtx.Header = header
+	case commitArgs.Type != "" && commitArgs.Scope == "":
+		commitCtx.Header.Scope = suggestedScope
+	}
+	if err := commitCtx.CheckConventional(); err != nil {
+		return err
+	}
+	log.Infof("conventional commit type: %s, scope: %s", commitCtx.Header.Type, commitCtx.Header.Scope)
+	return nil
+}
+
+// runGitCommit calls git commit with args unchanged
+func runGitCommit(args []string) {
+	gitArgs := append([]string{"commit"}, args...)
+	if err := core.RunGitCommand(gitArgs...); err != nil {
+		log.Fatalf("failed to commit: %v", err)
+	}
+	log.Info("commit successful")
+}
+
 func init() {
 	rootCmd.AddCommand(commitCmd)
 }
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configFormat string
+var configBranch string
+var configEditRepo bool
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, explain, validate and edit the zgit configuration",
+	Long: `Inspect and maintain the zgit configuration.
+
+The effective configuration is the user config.yaml with the repository-local
+.zgit.yaml layered over it.
+
+Examples:
+  zgit config show             # Print the effective config as YAML
+  zgit config show -o json     # Print the effective config as JSON
+  zgit config explain          # Explain the ticket of the current branch and the source of each setting
+  zgit config validate         # Report every problem in the config files
+  zgit config edit             # Edit the user config and re-validate it
+  zgit config edit --repo      # Edit the repository-local .zgit.yaml
+
+Any other config command is passed to git, e.g. zgit config user.name.`,
+	// Flags belong to the git config command passed through
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args = passthroughArgs(args)
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			_ = cmd.Help()
+			return
+		}
+		if err := core.RunGitCommand(append([]string{"config"}, args...)...); err != nil {
+			log.Fatalf("git command failed: %v", err)
+		}
+	},
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective merged configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig()
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+
+		switch configFormat {
+		case "yaml":
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			err = encoder.Encode(config)
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(config)
+		default:
+			log.Fatalf("unsupported output format: %s", configFormat)
+		}
+		if err != nil {
+			log.Fatalf("failed to encode config: %v", err)
+		}
+	},
+}
+
+// configExplainCmd represents the config explain command
+var configExplainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain which file, repository entry and pattern produce the ticket and each setting",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig()
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+
+		fmt.Println("Config files (lowest precedence first):")
+		for _, layer := range config.Layers() {
+			fmt.Printf("  %-10s %s\n", layer.Name, layer.Path)
+		}
+
+		repo, err := core.GetRepoIdentity()
+		if err != nil {
+			log.Fatalf("failed to get repository name: %v", err)
+		}
+		fmt.Printf("Repository:   %s (remote %s)\n", repo, repo.Remote)
+
+		branch := configBranch
+		if branch == "" {
+			branch, err = core.GetCurrentBranch()
+			if err != nil {
+				log.Fatalf("failed to get current branch: %v", err)
+			}
+		}
+		fmt.Printf("Branch:       %s\n", branch)
+
+		match, err := config.MatchBranch(repo, branch)
+		if err != nil && !errors.Is(err, core.ErrTicketNotFound) {
+			log.Fatalf("failed to match branch: %v", err)
+		}
+		if match.Repo != nil {
+			fmt.Printf("Repo entry:   %s (%s match) from %s\n", match.Repo.Name, match.RepoMatch, match.Repo.Source())
+		} else {
+			fmt.Println("Repo entry:   none, using global settings")
+		}
+		if match.Pattern != "" {
+			fmt.Printf("Pattern:      %s from %s\n", match.Pattern, match.PatternSource)
+		} else {
+			fmt.Println("Pattern:      none matches the branch")
+		}
+		// Without a prompt explain only reports what the fallbacks would find
+		if err := config.ResolveTicket(match, branch, core.TicketOptions{}); err != nil {
+			fmt.Printf("Ticket:       none (%v)\n", err)
+		} else {
+			fmt.Printf("Ticket:       %s from %s\n", match.Ticket, match.TicketSource)
+		}
+		fmt.Printf("Template:     %s from %s\n", match.Settings.Commit.Message, commitMessageSource(config, match))
+
+		fmt.Println("Settings:")
+		for _, setting := range config.SettingSources(match.Repo) {
+			fmt.Printf("  %-24s %s\n", setting.Key, settingSources(setting))
+		}
+	},
+}
+
+// settingSources describes where a setting comes from: every file for combined
+// settings, otherwise the file in effect and the files it overrides
+func settingSources(setting core.SettingSource) string {
+	if setting.Combined || len(setting.Sources) == 1 {
+		return strings.Join(setting.Sources, " + ")
+	}
+	return fmt.Sprintf("%s (overrides %s)", setting.Sources[0], strings.Join(setting.Sources[1:], ", "))
+}
+
+// commitMessageSource returns the config file the effective commit message template came from
+func commitMessageSource(config *core.Config, match *core.BranchMatch) string {
+	if match.Repo != nil && match.Repo.Commit.Message != "" {
+		return match.Repo.Source()
+	}
+	if sources := config.Sources("global.commit.message"); len(sources) > 0 {
+		return sources[0].Path
+	}
+	return ""
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Compile every pattern and template and report all problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !validateConfigFiles() {
+			os.Exit(1)
+		}
+	},
+}
+
+// validateConfigFiles prints every config problem and reports whether the config is valid
+func validateConfigFiles() bool {
+	layers, err := core.ValidateConfig()
+	for _, layer := range layers {
+		log.Infof("checked %s config file: %s", layer.Name, layer.Path)
+	}
+
+	var validationErrs core.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		for _, validationErr := range validationErrs {
+			fmt.Println(validationErr)
+		}
+		return false
+	} else if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	fmt.Println("config is valid")
+	return true
+}
+
+// configEditCmd represents the config edit command
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config in your editor and re-validate it on save",
+	Run: func(cmd *cobra.Command, args []string) {
+		var path string
+		var err error
+		if configEditRepo {
+			path, err = core.GetRepoConfigPath()
+		} else {
+			path, err = core.GetUserConfigPath()
+		}
+		if err != nil {
+			log.Fatalf("failed to locate config file: %v", err)
+		}
+
+		// Keep the original content to restore it if the user gives up on an invalid edit
+		original, err := os.ReadFile(path)
+		existed := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Fatalf("failed to read %s: %v", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalf("failed to create config directory: %v", err)
+		}
+
+		for {
+			log.Infof("editing %s", path)
+			if err := core.RunEditor(path); err != nil {
+				log.Fatalf("failed to run editor: %v", err)
+			}
+			if validateConfigFiles() {
+				return
+			}
+
+			again, err := confirm("Edit again?", true)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if again {
+				continue
+			}
+
+			if existed {
+				err = os.WriteFile(path, original, 0644)
+			} else {
+				err = os.Remove(path)
+			}
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Fatalf("failed to restore %s: %v", path, err)
+			}
+			log.Infof("changes to %s reverted", path)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd, configExplainCmd, configValidateCmd, configEditCmd)
+	configShowCmd.Flags().StringVarP(&configFormat, "output", "o", "yaml", "Output format: yaml or json")
+	configExplainCmd.Flags().StringVarP(&configBranch, "branch", "b", "", "Branch to explain (default: current branch)")
+	configEditCmd.Flags().BoolVar(&configEditRepo, "repo", false, "Edit the repository-local .zgit.yaml instead of the user config")
+}
@@ -2,6 +2,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"zhaojunlucky/zgit/core"
 
@@ -11,13 +12,57 @@ import (
 
 // forcePullCmd represents the forcePull command
 var forcePullCmd = &cobra.Command{
-	Use:   "force-pull",
-	Short: "Force pull by deleting local branch and checking out from origin",
+	Use:   "force-pull [branch...]",
+	Short: "Force pull by resetting the local branch to its upstream",
 	Long: `Force pull is useful when the remote branch has been force-pushed.
-It deletes the current local branch, fetches from origin, and checks out 
-the branch again from the remote, effectively syncing with the force-pushed changes.`,
+It fetches the upstream of the current branch and resets the local branch to
+it, effectively syncing with the force-pushed changes. Nothing changes when the
+fetch fails, and the branch is rolled back to its old tip if a later step fails.
+
+The upstream is the branch.<name>.remote and branch.<name>.merge the branch
+tracks, so fork workflows and branches named differently on the remote work,
+or the branch of the same name on origin. --remote picks another remote and
+the branch of the same name on it. The branch tracks the upstream afterwards,
+also with --rebase.
+
+Force pull refuses to run when tracked files have uncommitted changes or the
+branch has commits that are not on any remote, unless --force is given. The
+old tip of the branch is always saved as refs/zgit/backup/<branch>/<timestamp>,
+and --restore resets the branch to its newest backup.
+
+Branches given as arguments are force-pulled without checking them out, their
+refs are updated in place. --all force-pulls every branch tracking a remote
+branch that was force-pushed, detected by a fetch that is not a fast-forward
+or by the commit the branch forked from no longer being on the remote branch,
+and skips the others, even if they have local commits the remote lacks. Both
+print a summary per branch. A branch checked out in another worktree is
+skipped, force-pull it there.
+
+With --rebase the branch is kept and only the local commits made on top of the
+old remote tip are replayed onto the new one, the old tip is found in the
+reflog of the remote-tracking branch.
+
+Examples:
+  zgit force-pull                # Sync the current branch with its upstream
+  zgit force-pull -r upstream    # Sync with the branch of the same name on upstream
+  zgit force-pull feat-a feat-b  # Sync other branches without checking them out
+  zgit force-pull --all          # Sync every branch whose remote was force-pushed
+  zgit force-pull --rebase       # Replay local commits onto the rewritten branch
+  zgit force-pull --force        # Sync even if local work would be dropped
+  zgit force-pull --restore      # Undo the last force-pull of the branch`,
+	// Errors are about the repository state, not the command line
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		sourceBranch, _ := cmd.Flags().GetString("branch")
+		force, _ := cmd.Flags().GetBool("force")
+		restore, _ := cmd.Flags().GetBool("restore")
+		rebase, _ := cmd.Flags().GetBool("rebase")
+		remote, _ := cmd.Flags().GetString("remote")
+		all, _ := cmd.Flags().GetBool("all")
+		opts := forcePullOptions{remote: remote, force: force, onlyRewritten: all}
+
+		if core.RebaseInProgress() {
+			return errors.New("a rebase is in progress, finish it with git rebase --continue or git rebase --abort")
+		}
 
 		// Get current branch name
 		currentBranch, err := core.GetCurrentBranch()
@@ -25,35 +70,368 @@ the branch again from the remote, effectively syncing with the force-pushed chan
 			return fmt.Errorf("failed to get current branch: %w", err)
 		}
 
+		if all || len(args) > 0 {
+			if rebase || restore {
+				return errors.New("--rebase and --restore only work on the current branch")
+			}
+			if all && len(args) > 0 {
+				return errors.New("give branches or --all, not both")
+			}
+			return forcePullBranches(args, currentBranch, opts)
+		}
+
+		if currentBranch == "HEAD" {
+			return errors.New("HEAD is detached, check out the branch to force-pull")
+		}
 		logrus.Infof("Current branch: %s", currentBranch)
 
-		// Checkout to source branch
-		if err := core.RunGitCommand("checkout", sourceBranch); err != nil {
-			return fmt.Errorf("failed to checkout to %s: %w", sourceBranch, err)
+		if rebase {
+			upstream, err := core.GetBranchUpstream(currentBranch, remote)
+			if err != nil {
+				return err
+			}
+			return rebaseOntoRemote(currentBranch, upstream)
+		}
+		if restore {
+			// The current tip is backed up, only uncommitted changes are lost
+			if err := checkWorkingTree(force); err != nil {
+				return err
+			}
+			return restoreBackup(currentBranch)
+		}
+
+		branch, err := core.GetLocalBranch(currentBranch)
+		if err != nil {
+			return err
+		}
+		status, detail, err := forcePullBranch(branch, true, opts)
+		if err != nil {
+			return err
 		}
+		if status == statusSkipped {
+			return errors.New(detail)
+		}
+		logrus.Infof("Successfully force-pulled branch: %s (%s)", currentBranch, detail)
+		return nil
+	},
+}
+
+// forcePullOptions are the flags shared by every branch of a force-pull
+type forcePullOptions struct {
+	remote string
+	force  bool
+	// onlyRewritten skips branches whose upstream was not force-pushed
+	onlyRewritten bool
+}
+
+// Statuses of a branch in the force-pull summary
+const (
+	statusUpdated  = "updated"
+	statusUpToDate = "up to date"
+	statusSkipped  = "skipped"
+	statusFailed   = "failed"
+)
 
-		// Delete the local branch
-		if err := core.RunGitCommand("branch", "-D", currentBranch); err != nil {
-			return fmt.Errorf("failed to delete branch %s: %w", currentBranch, err)
+// forcePullBranches force-pulls the named branches, or with --all every branch
+// tracking a remote branch that was force-pushed, and prints a summary
+func forcePullBranches(names []string, currentBranch string, opts forcePullOptions) error {
+	branches, err := core.ListLocalBranches()
+	if err != nil {
+		return err
+	}
+	byName := map[string]core.LocalBranch{}
+	for _, branch := range branches {
+		byName[branch.Name] = branch
+	}
+	if opts.onlyRewritten {
+		for _, branch := range branches {
+			if branch.TracksRemote() {
+				names = append(names, branch.Name)
+			}
 		}
-		logrus.Infof("Deleted local branch: %s", currentBranch)
+	}
 
-		// Fetch from origin
-		if err := core.RunGitCommand("fetch", "origin", currentBranch); err != nil {
-			return fmt.Errorf("failed to fetch from origin: %w", err)
+	var results []summaryRow
+	for _, name := range names {
+		branch, ok := byName[name]
+		if !ok {
+			results = append(results, summaryRow{name, statusFailed, "no such branch"})
+			continue
 		}
-		logrus.Info("Fetched from origin")
+		status, detail, err := forcePullBranch(branch, name == currentBranch, opts)
+		if err != nil {
+			status, detail = statusFailed, err.Error()
+		}
+		results = append(results, summaryRow{name, status, detail})
+	}
+
+	if failed := printSummary("Force-pull summary:", results); failed > 0 {
+		return fmt.Errorf("force-pull failed for %d of %d branches", failed, len(results))
+	}
+	return nil
+}
 
-		// Checkout the branch from origin
-		if err := core.RunGitCommand("checkout", "-b", currentBranch, fmt.Sprintf("origin/%s", currentBranch)); err != nil {
-			return fmt.Errorf("failed to checkout branch %s from origin: %w", currentBranch, err)
+// summaryRow is the outcome for one branch or worktree of a bulk operation
+type summaryRow struct {
+	name, status, detail string
+}
+
+// printSummary prints rows as an aligned table and returns how many failed
+func printSummary(title string, rows []summaryRow) int {
+	width, failed := 0, 0
+	for _, row := range rows {
+		width = max(width, len(row.name))
+		if row.status == statusFailed {
+			failed++
 		}
-		logrus.Infof("Successfully force-pulled branch: %s", currentBranch)
+	}
+	fmt.Println(title)
+	for _, row := range rows {
+		fmt.Printf("  %-*s  %-12s  %s\n", width, row.name, row.status, row.detail)
+	}
+	return failed
+}
+
+// forcePullBranch fetches the upstream of branch and moves the branch to it.
+// The current branch is reset with its worktree, any other branch ref is
+// updated in place. It returns the status and a detail for the summary.
+func forcePullBranch(branch core.LocalBranch, current bool, opts forcePullOptions) (string, string, error) {
+	upstream, err := core.GetBranchUpstream(branch.Name, opts.remote)
+	if err != nil {
+		return "", "", err
+	}
+	// Moving the branch of another worktree would leave its files behind the ref
+	if !current && branch.Worktree != "" {
+		return statusSkipped, fmt.Sprintf("checked out in %s, force-pull it there", branch.Worktree), nil
+	}
+
+	// Fetch first so a failure leaves the branch untouched
+	oldUpstreamTip, _ := core.ResolveCommit(upstream.TrackingRef())
+	if err := core.FetchUpstream(upstream); err != nil {
+		return "", "", fmt.Errorf("failed to fetch %s: %w", upstream, err)
+	}
+	newTip, err := core.ResolveCommit(upstream.TrackingRef())
+	if err != nil {
+		return "", "", fmt.Errorf("%s does not exist after fetching", upstream)
+	}
+	logrus.Infof("Fetched %s", upstream)
+
+	if newTip == branch.Tip {
+		if err := core.SetBranchUpstream(branch.Name, upstream.Remote, upstream.Branch); err != nil {
+			return "", "", fmt.Errorf("failed to track %s: %w", upstream, err)
+		}
+		return statusUpToDate, upstream.String(), nil
+	}
+	if opts.onlyRewritten && !isRewritten(branch.Name, upstream, oldUpstreamTip, newTip) {
+		return statusSkipped, fmt.Sprintf("%s was not force-pushed", upstream), nil
+	}
+	// The commits of the old upstream were pushed even though no remote has them now
+	pushed := []string{}
+	if oldUpstreamTip != "" {
+		pushed = append(pushed, oldUpstreamTip)
+	}
+	if forkPoint, err := core.ForkPoint(upstream.TrackingRef(), branch.Name); err == nil {
+		pushed = append(pushed, forkPoint)
+	}
+	if err := checkLocalWork(branch.Name, current, opts.force, pushed...); err != nil {
+		return statusSkipped, err.Error(), nil
+	}
+
+	// Keep the old tip so the force-pull can be undone
+	backup, err := core.BackupBranch(branch.Name)
+	if err != nil {
+		return "", "", err
+	}
+	logrus.Infof("Backed up %s as %s", branch.Name, backup)
+
+	if current {
+		err = resetBranch(branch.Name, upstream, branch.Tip)
+	} else {
+		err = updateBranch(branch.Name, upstream, newTip, branch.Tip)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return statusUpdated, fmt.Sprintf("%.7s -> %.7s from %s", branch.Tip, newTip, upstream), nil
+}
+
+// isRewritten reports whether the upstream was force-pushed, judged from its
+// history only: the fetch was not a fast-forward, or the commit branch forked
+// from, found in the reflog of the remote-tracking branch, is no longer in the
+// upstream after an earlier fetch. Local commits the upstream lacks do not count.
+func isRewritten(branch string, upstream core.Upstream, oldUpstreamTip, newUpstreamTip string) bool {
+	if oldUpstreamTip != "" && !core.IsAncestor(oldUpstreamTip, newUpstreamTip) {
+		return true
+	}
+	forkPoint, err := core.ForkPoint(upstream.TrackingRef(), branch)
+	return err == nil && !core.IsAncestor(forkPoint, newUpstreamTip)
+}
+
+// resetBranch moves the checked-out branch and the worktree to upstream and
+// tracks it, if a step fails the branch is reset to oldTip again
+func resetBranch(branch string, upstream core.Upstream, oldTip string) (err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rollbackErr := core.RunGitCommand("reset", "--hard", "--quiet", oldTip); rollbackErr != nil {
+			err = fmt.Errorf("%w; rolling back to %s failed too: %v", err, oldTip, rollbackErr)
+			return
+		}
+		logrus.Warnf("Rolled back %s to %s", branch, oldTip)
+	}()
+
+	// reset --hard updates the branch ref and the worktree together and drops
+	// the uncommitted changes --force allowed
+	if err := core.RunGitCommand("reset", "--hard", upstream.TrackingRef()); err != nil {
+		return fmt.Errorf("failed to reset %s to %s: %w", branch, upstream, err)
+	}
+	if err := core.SetBranchUpstream(branch, upstream.Remote, upstream.Branch); err != nil {
+		return fmt.Errorf("failed to track %s: %w", upstream, err)
+	}
+	return nil
+}
+
+// updateBranch moves a branch that is not checked out from oldTip to newTip
+// and tracks upstream, if tracking fails the branch is moved back
+func updateBranch(branch string, upstream core.Upstream, newTip, oldTip string) error {
+	if err := core.UpdateBranch(branch, newTip, oldTip, "zgit: force-pull from "+upstream.String()); err != nil {
+		return err
+	}
+	if err := core.SetBranchUpstream(branch, upstream.Remote, upstream.Branch); err != nil {
+		if rollbackErr := core.UpdateBranch(branch, oldTip, newTip, "zgit: roll back force-pull"); rollbackErr != nil {
+			return fmt.Errorf("failed to track %s: %w; rolling back to %s failed too: %v", upstream, err, oldTip, rollbackErr)
+		}
+		return fmt.Errorf("failed to track %s: %w", upstream, err)
+	}
+	return nil
+}
+
+// checkLocalWork refuses to drop commits that are not on any remote and, for
+// the checked-out branch, uncommitted changes unless force is set. Commits
+// reachable from pushed count as being on a remote.
+func checkLocalWork(branch string, checkedOut, force bool, pushed ...string) error {
+	if force {
 		return nil
-	},
+	}
+	if checkedOut {
+		if err := checkWorkingTree(force); err != nil {
+			return err
+		}
+	}
+	unpushed, err := core.UnpushedCommits(branch, pushed...)
+	if err != nil {
+		return err
+	}
+	for _, commit := range unpushed {
+		logrus.Warnf("%s: not on any remote: %s", branch, commit)
+	}
+	if len(unpushed) > 0 {
+		return fmt.Errorf("branch %s has %d commit(s) that are not on any remote, use --force to drop them (a backup is kept)", branch, len(unpushed))
+	}
+	return nil
+}
+
+// checkWorkingTree refuses to discard uncommitted changes unless force is set
+func checkWorkingTree(force bool) error {
+	if force {
+		return nil
+	}
+	dirty, err := core.HasUncommittedChanges()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return errors.New("tracked files have uncommitted changes, commit or stash them, or use --force to discard them")
+	}
+	return nil
+}
+
+// rebaseOntoRemote fetches the rewritten remote branch and replays the commits
+// made on top of its old tip onto the new tip
+func rebaseOntoRemote(branch string, upstream core.Upstream) error {
+	dirty, err := core.HasUncommittedChanges()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return errors.New("tracked files have uncommitted changes, commit or stash them before rebasing")
+	}
+
+	trackingRef := upstream.TrackingRef()
+	oldTip, _ := core.ResolveCommit(trackingRef)
+	if err := core.FetchUpstream(upstream); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", upstream, err)
+	}
+	logrus.Infof("Fetched %s", upstream)
+
+	// The reflog of the remote-tracking branch knows the old tip even when it
+	// was fetched earlier, the tip before this fetch is the fallback
+	base, err := core.ForkPoint(trackingRef, branch)
+	if err != nil {
+		if oldTip == "" {
+			return fmt.Errorf("cannot find where %s forked from %s, rebase it yourself with git rebase --onto %s <old-tip>", branch, upstream, upstream)
+		}
+		base = oldTip
+	}
+	count, err := core.CountCommits(base + ".." + branch)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Replaying %d local commits onto %s", count, upstream)
+
+	backup, err := core.BackupBranch(branch)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Backed up %s as %s", branch, backup)
+	// Track the upstream before rebasing, a rebase stopped on a conflict ends
+	// with git rebase --continue
+	if err := core.SetBranchUpstream(branch, upstream.Remote, upstream.Branch); err != nil {
+		return fmt.Errorf("failed to track %s: %w", upstream, err)
+	}
+	if err := core.RunGitCommand("rebase", "--onto", trackingRef, base, branch); err != nil {
+		return fmt.Errorf("rebase stopped: resolve the conflicts and run git rebase --continue, "+
+			"or run git rebase --abort to keep the branch as it was (also saved as %s)", backup)
+	}
+	logrus.Infof("Successfully rebased branch %s onto %s", branch, upstream)
+	return nil
+}
+
+// restoreBackup resets branch to its newest backup, backing up the current tip
+// first so restoring twice goes back to the force-pulled state
+func restoreBackup(branch string) error {
+	backups, err := core.ListBackups(branch)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("branch %s has no backup to restore", branch)
+	}
+	restored := backups[0]
+
+	backup, err := core.BackupBranch(branch)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Backed up %s as %s", branch, backup)
+	if err := core.RunGitCommand("reset", "--hard", restored); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", restored, err)
+	}
+	if err := core.DeleteRef(restored); err != nil {
+		logrus.Warnf("failed to delete restored backup %s: %v", restored, err)
+	}
+	logrus.Infof("Restored branch %s from %s", branch, restored)
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(forcePullCmd)
 	forcePullCmd.Flags().StringP("branch", "b", "main", "The source branch to checkout before deleting current branch")
+	_ = forcePullCmd.Flags().MarkDeprecated("branch", "force-pull no longer leaves the current branch")
+	forcePullCmd.Flags().BoolP("force", "f", false, "Discard uncommitted changes and commits that are not on the remote")
+	forcePullCmd.Flags().Bool("restore", false, "Reset the current branch to its newest backup")
+	forcePullCmd.Flags().Bool("rebase", false, "Replay the local commits onto the rewritten remote branch")
+	forcePullCmd.Flags().StringP("remote", "r", "", "Remote to pull from (default: the remote the branch tracks, or origin)")
+	forcePullCmd.Flags().Bool("all", false, "Force-pull every branch whose remote branch was force-pushed")
 }
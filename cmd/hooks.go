@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// hooksCmd represents the hooks command
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage the git hooks that add the ticket to every commit",
+	Long: `Install git hooks so commits made with plain git, from an IDE, with an
+editor, -F file, merges and rebases get the ticket from the branch name too.
+
+The prepare-commit-msg and commit-msg hooks are written to the hooks directory
+of the repository, honouring core.hooksPath. Existing hooks are kept and
+called before zgit.
+
+Examples:
+  zgit hooks install    # Install the hooks in the current repository
+  zgit hooks status     # Show which hooks are installed
+  zgit hooks uninstall  # Remove the hooks and restore the previous ones`,
+}
+
+// hooksInstallCmd represents the hooks install command
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the zgit commit hooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		zgitPath, err := os.Executable()
+		if err != nil {
+			log.Fatalf("failed to locate zgit executable: %v", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(zgitPath); err == nil {
+			zgitPath = resolved
+		}
+
+		if err := core.InstallHooks(zgitPath); err != nil {
+			log.Fatalf("failed to install hooks: %v", err)
+		}
+		printHooksStatus()
+	},
+}
+
+// hooksUninstallCmd represents the hooks uninstall command
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the zgit commit hooks and restore the previous hooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := core.UninstallHooks(); err != nil {
+			log.Fatalf("failed to uninstall hooks: %v", err)
+		}
+		printHooksStatus()
+	},
+}
+
+// hooksStatusCmd represents the hooks status command
+var hooksStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which zgit commit hooks are installed",
+	Run: func(cmd *cobra.Command, args []string) {
+		printHooksStatus()
+	},
+}
+
+// printHooksStatus prints the state of every managed hook
+func printHooksStatus() {
+	statuses, err := core.GetHooksStatus()
+	if err != nil {
+		log.Fatalf("failed to get hooks status: %v", err)
+	}
+	for _, status := range statuses {
+		state := "not installed"
+		if status.Installed {
+			state = "installed"
+		} else if status.Foreign {
+			state = "not installed (other hook present)"
+		}
+		if status.Chained {
+			state += ", chains " + status.Path + ".zgit-chained"
+		}
+		fmt.Printf("%-20s %s (%s)\n", status.Name, state, status.Path)
+	}
+	if core.IsLinkedWorktree() {
+		fmt.Println("The hooks are shared by every worktree of the repository")
+	}
+}
+
+// hooksRunCmd is called by the installed hook scripts
+var hooksRunCmd = &cobra.Command{
+	Use:    "run <hook> <args>...",
+	Short:  "Run a zgit hook, called by the installed hook scripts",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Keep commits from IDEs and plain git quiet
+		log.SetLevel(log.WarnLevel)
+		if err := runHook(args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "zgit: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// gitMessageSources are the prepare-commit-msg sources of messages git builds
+// itself: merges, squashes, and the reused message of --amend, -c and -C
+var gitMessageSources = map[string]bool{"merge": true, "squash": true, "commit": true}
+
+// runHook applies the commit message template to the message file of a commit hook
+func runHook(name string, args []string) error {
+	if os.Getenv(core.SkipHooksEnv) != "" {
+		return nil
+	}
+	if name != "prepare-commit-msg" && name != "commit-msg" {
+		return fmt.Errorf("unsupported hook: %s", name)
+	}
+
+	// git passes the source of the message to prepare-commit-msg
+	if name == "prepare-commit-msg" && len(args) > 1 && gitMessageSources[args[1]] {
+		return nil
+	}
+	msgFile, err := core.ReadCommitMessageFile(args[0])
+	if err != nil {
+		return err
+	}
+	// Merges, reverts and autosquash markers keep the subject git wrote,
+	// the conventional type check would otherwise block them
+	if subject := msgFile.Subject(); core.IsAutosquashSubject(subject) || core.IsRevertSubject(subject) || core.MergeInProgress() {
+		return nil
+	}
+
+	// Hooks run without a terminal, so the ticket is never prompted for
+	commitCtx, err := core.LoadCommitContext(core.TicketOptions{})
+	if err != nil {
+		// A branch without ticket must not block the commit
+		log.Warnf("zgit: commit message left unchanged: %v", err)
+		return nil
+	}
+	if err := commitCtx.TakeConventionalHeader(msgFile.CommitMessage); err != nil {
+		return fmt.Errorf("failed to render commit message template: %w", err)
+	}
+	if err := commitCtx.CheckConventional(); err != nil {
+		// The type cannot be asked for here, only commit-msg rejects the message
+		if name == "commit-msg" {
+			return err
+		}
+		return nil
+	}
+
+	emptyMessage, err := commitCtx.Render("")
+	if err != nil {
+		return fmt.Errorf("failed to render commit message template: %w", err)
+	}
+	subject := msgFile.Subject()
+	original := msgFile.String()
+
+	switch {
+	case subject == "":
+		if name == "commit-msg" {
+			return nil
+		}
+		// Editor commit: prefill the ticket so the user types the message after it
+		msgFile.SetSubject(emptyMessage)
+	case strings.TrimSpace(subject) == strings.TrimSpace(emptyMessage):
+		if name == "commit-msg" {
+			return errors.New("aborting commit due to empty commit message")
+		}
+		return nil
+	default:
+		satisfied, err := commitCtx.Satisfies(subject)
+		if err != nil {
+			return fmt.Errorf("failed to render commit message template: %w", err)
+		}
+		if !satisfied {
+			rendered, err := commitCtx.Render(subject)
+			if err != nil {
+				return fmt.Errorf("failed to render commit message template: %w", err)
+			}
+			msgFile.SetSubject(rendered)
+		}
+	}
+	if name == "commit-msg" {
+		// Trailers are added once the message is final
+		commitCtx.CoAuthors, err = commitCtx.Match.Settings.ResolveCoAuthors(core.GetPair())
+		if err != nil {
+			return err
+		}
+		if err := commitCtx.ApplyTrailers(msgFile.CommitMessage); err != nil {
+			return err
+		}
+	}
+	if name == "commit-msg" && commitCtx.Match.Settings.Lint.IsEnforced() {
+		if err := lintCommitMessage(commitCtx, msgFile.String()); err != nil {
+			return err
+		}
+	}
+	if msgFile.String() == original {
+		return nil
+	}
+	return msgFile.Write()
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	hooksCmd.AddCommand(hooksInstallCmd, hooksUninstallCmd, hooksStatusCmd, hooksRunCmd)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var lintFormat string
+var lintFile string
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [revision-range]...",
+	Short: "Check commit messages against the template and the lint rules",
+	Long: `Check commit messages against the commit message template and the lint
+rules of the config, and exit with status 1 if any message breaks a rule.
+
+Without a range the commits of the current branch that are not on its
+upstream are checked, or the last commit if the branch has no upstream.
+
+Rules: template, ticket, branch-ticket, subject-length, imperative,
+trailing-period and body-wrap. Configure them in the lint section:
+
+  global:
+    lint:
+      rules: [template, ticket, subject-length]   # all rules by default
+      subject-length: 72
+      body-width: 72
+      non-imperative: [added, fixed, updated]
+      enforce: true   # also lint in zgit commit and the commit-msg hook
+
+Examples:
+  zgit lint                        # Lint the unpushed commits of the branch
+  zgit lint origin/main..HEAD      # Lint a range, e.g. in CI
+  zgit lint -o json main..HEAD     # Print the problems as JSON
+  zgit lint --file .git/COMMIT_EDITMSG  # Lint a message file, e.g. from a commit-msg hook`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if lintFormat != "human" && lintFormat != "json" {
+			log.Fatalf("unsupported output format: %s", lintFormat)
+		}
+		commitCtx, err := core.LoadLintContext()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var results []core.LintResult
+		if lintFile != "" {
+			if len(args) > 0 {
+				log.Fatal("a revision range cannot be used with --file")
+			}
+			result, err := lintMessageFile(commitCtx, lintFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			results = append(results, result)
+		} else {
+			if len(args) == 0 {
+				args = defaultLintRange()
+			}
+			log.Infof("linting commits: %s", strings.Join(args, " "))
+			results, err = commitCtx.LintRange(args...)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		failed := printLintResults(results)
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// defaultLintRange returns the commits of the current branch not on its upstream,
+// or the last commit if there is no upstream
+func defaultLintRange() []string {
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", "@{upstream}").Run(); err == nil {
+		return []string{"@{upstream}..HEAD"}
+	}
+	return []string{"-1", "HEAD"}
+}
+
+// lintMessageFile lints the message in path, "-" reads stdin
+func lintMessageFile(commitCtx *core.CommitContext, path string) (core.LintResult, error) {
+	text, err := core.ReadMessageFile(path)
+	if err != nil {
+		return core.LintResult{}, err
+	}
+	return lintMessage(commitCtx, text)
+}
+
+// lintMessage lints a message that is not committed yet, comment lines are ignored like git does
+func lintMessage(commitCtx *core.CommitContext, text string) (core.LintResult, error) {
+	message := core.ParseCommitMessage(text, true)
+	message.StripComments()
+	problems, err := commitCtx.Lint(message.String())
+	if err != nil {
+		return core.LintResult{}, err
+	}
+	return core.LintResult{Subject: message.Subject(), Problems: problems}, nil
+}
+
+// printLintResults prints the results in the chosen format and reports whether any message has problems
+func printLintResults(results []core.LintResult) bool {
+	failed := 0
+	for _, result := range results {
+		if len(result.Problems) > 0 {
+			failed++
+		}
+	}
+
+	if lintFormat == "json" {
+		if results == nil {
+			results = []core.LintResult{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			log.Fatalf("failed to encode lint results: %v", err)
+		}
+		return failed > 0
+	}
+
+	for _, result := range results {
+		if len(result.Problems) == 0 {
+			continue
+		}
+		name := "message"
+		if result.Commit != "" {
+			name = result.Commit[:min(len(result.Commit), 12)]
+		}
+		fmt.Printf("%s %s\n", name, result.Subject)
+		for _, problem := range result.Problems {
+			fmt.Printf("  %-16s %s\n", problem.Rule+":", problem.Message)
+		}
+	}
+	fmt.Printf("%d message(s) checked, %d with problems\n", len(results), failed)
+	return failed > 0
+}
+
+// lintCommitMessage returns an error listing the problems of message
+func lintCommitMessage(commitCtx *core.CommitContext, message string) error {
+	result, err := lintMessage(commitCtx, message)
+	if err != nil {
+		return err
+	}
+	if len(result.Problems) == 0 {
+		return nil
+	}
+	var problems []string
+	for _, problem := range result.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %s", problem.Rule, problem.Message))
+	}
+	return fmt.Errorf("commit message breaks the lint rules: %s", strings.Join(problems, "; "))
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVarP(&lintFormat, "output", "o", "human", "Output format: human or json")
+	lintCmd.Flags().StringVar(&lintFile, "file", "", "Lint the message in this file instead of commits, - reads stdin")
+}
@@ -5,38 +5,65 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
-	"regexp"
 	"runtime"
-	"strings"
+	"zhaojunlucky/zgit/core"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var remoteName string
+var openBranch bool
+var openBlame bool
+var openIssues bool
+var openActions bool
 
 // openCmd represents the open command
 var openCmd = &cobra.Command{
-	Use:   "open",
-	Short: "Open the GitHub repository in your browser",
-	Long: `Open the GitHub repository URL in your default web browser.
+	Use:   "open [path[:line[-line]] | commit]",
+	Short: "Open the repository, a file or a commit in your browser",
+	Long: `Open the repository page on GitHub, GitLab, Bitbucket, Azure DevOps or
+Gitea in your default web browser, or the page of a file, lines of a file, a
+commit, the current branch, the issues or the CI runs.
+
+Paths are relative to the current directory, like for git. Files and blames
+are pinned to the commit checked out, so the link keeps pointing at the same
+lines; --branch links to the remote branch instead. A warning tells when the
+commit or the file is not pushed yet.
 
 By default, it uses the 'origin' remote. You can specify a different remote
 using the -r or --remote flag.
 
+The forge is detected from the host name, GitHub otherwise. Set it for hosts
+that do not tell, or when the web UI is not at https://<host>:
+
+  global:
+    forges:
+      - host: git.corp.com
+        type: gitlab   # github, gitlab, bitbucket, bitbucket-server, azure-devops or gitea
+        url: https://git.corp.com/gitlab
+
 Examples:
-  zgit open              # Opens the origin remote's GitHub page
-  zgit open -r upstream  # Opens the upstream remote's GitHub page`,
+  zgit open                          # Opens the origin remote's page
+  zgit open -r upstream              # Opens the upstream remote's page
+  zgit open core/config.go:42-60     # Opens lines 42 to 60 at the current commit
+  zgit open core/config.go --branch  # Opens the file on the current branch
+  zgit open --blame core/config.go   # Opens the blame of the file
+  zgit open a1b2c3d                  # Opens a commit
+  zgit open --branch                 # Opens the current branch
+  zgit open --issues                 # Opens the issues
+  zgit open --actions                # Opens the CI runs: actions, pipelines or builds`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		url, err := getRemoteURL(remoteName)
+		forge, err := remoteForge(remoteName)
 		if err != nil {
-			log.Fatalf("failed to get remote URL: %v", err)
+			log.Fatalf("failed to get the forge of %s: %v", remoteName, err)
 		}
-
-		webURL, err := parseGitURLToWeb(url)
+		webURL, err := openURL(forge, args)
 		if err != nil {
-			log.Fatalf("failed to parse git URL: %v", err)
+			log.Fatal(err)
 		}
 
 		log.Infof("opening %s", webURL)
@@ -46,55 +73,121 @@ Examples:
 	},
 }
 
-// getRemoteURL gets the URL for the specified remote
-func getRemoteURL(remote string) (string, error) {
-	cmd := exec.Command("git", "remote", "get-url", remote)
-	output, err := cmd.Output()
+// openURL returns the URL of the page the arguments and flags select
+func openURL(forge core.Forge, args []string) (string, error) {
+	switch {
+	case openIssues || openActions:
+		if len(args) > 0 {
+			return "", fmt.Errorf("--issues and --actions take no path or commit")
+		}
+		if openActions {
+			return forge.PipelinesURL(), nil
+		}
+		if forge.IssuesURL() == "" {
+			return "", fmt.Errorf("%s has no issue tracker", forge.Type())
+		}
+		return forge.IssuesURL(), nil
+	case len(args) == 0 && openBlame:
+		return "", fmt.Errorf("--blame needs a file")
+	case len(args) == 0 && openBranch:
+		branch, err := remoteBranch()
+		if err != nil {
+			return "", err
+		}
+		return forge.BranchURL(branch), nil
+	case len(args) == 0:
+		return forge.RepoURL(), nil
+	}
+
+	arg := args[0]
+	path, lines, err := core.SplitFileLines(arg)
+	if err != nil {
+		return "", err
+	}
+	// A file name with a colon is not a line range
+	if _, err := os.Stat(arg); err == nil {
+		path, lines = arg, core.LineRange{}
+	}
+	info, err := os.Stat(path)
 	if err != nil {
-		return "", fmt.Errorf("remote '%s' not found", remote)
+		commit, commitErr := core.ResolveCommit(arg)
+		if openBlame || commitErr != nil {
+			return "", fmt.Errorf("%s is neither a file nor a commit", arg)
+		}
+		warnUnpushed(commit)
+		return forge.CommitURL(commit), nil
 	}
-	return strings.TrimSpace(string(output)), nil
+	if openBlame && info.IsDir() {
+		return "", fmt.Errorf("--blame needs a file, %s is a directory", path)
+	}
+	return fileURL(forge, path, lines)
 }
 
-// parseGitURLToWeb converts a git remote URL to a web browser URL
-// Supports:
-//   - SSH: git@github.com:owner/repo.git
-//   - HTTPS: https://github.com/owner/repo.git
-//   - SSH with ssh:// prefix: ssh://git@github.com/owner/repo.git
-func parseGitURLToWeb(gitURL string) (string, error) {
-	gitURL = strings.TrimSpace(gitURL)
-	gitURL = strings.TrimSuffix(gitURL, ".git")
-
-	// SSH format: git@github.com:owner/repo
-	if strings.HasPrefix(gitURL, "git@") {
-		// git@github.com:owner/repo -> https://github.com/owner/repo
-		re := regexp.MustCompile(`^git@([^:]+):(.+)$`)
-		matches := re.FindStringSubmatch(gitURL)
-		if len(matches) == 3 {
-			host := matches[1]
-			path := matches[2]
-			return fmt.Sprintf("https://%s/%s", host, path), nil
+// fileURL returns the URL of path, or its blame, at the current commit or
+// with --branch on the remote branch
+func fileURL(forge core.Forge, path string, lines core.LineRange) (string, error) {
+	repoPath, err := core.RepoPath(path)
+	if err != nil {
+		return "", err
+	}
+	head, err := core.ResolveCommit("HEAD")
+	if err != nil {
+		return "", fmt.Errorf("no commit checked out: %v", err)
+	}
+	if repoPath != "" && !core.FileInCommit(head, repoPath) {
+		log.Warnf("%s is not committed, the link will not work until it is pushed", repoPath)
+	}
+	ref := head
+	if openBranch {
+		if ref, err = remoteBranch(); err != nil {
+			return "", err
 		}
+	} else {
+		warnUnpushed(head)
 	}
 
-	// SSH with ssh:// prefix: ssh://git@github.com/owner/repo
-	if strings.HasPrefix(gitURL, "ssh://") {
-		// ssh://git@github.com/owner/repo -> https://github.com/owner/repo
-		re := regexp.MustCompile(`^ssh://git@([^/]+)/(.+)$`)
-		matches := re.FindStringSubmatch(gitURL)
-		if len(matches) == 3 {
-			host := matches[1]
-			path := matches[2]
-			return fmt.Sprintf("https://%s/%s", host, path), nil
-		}
+	if !openBlame {
+		return forge.FileURL(ref, repoPath, lines), nil
 	}
+	if forge.BlameURL(ref, repoPath, lines) == "" {
+		return "", fmt.Errorf("%s has no blame page", forge.Type())
+	}
+	return forge.BlameURL(ref, repoPath, lines), nil
+}
 
-	// HTTPS format: https://github.com/owner/repo
-	if strings.HasPrefix(gitURL, "https://") || strings.HasPrefix(gitURL, "http://") {
-		return gitURL, nil
+// remoteBranch returns the name on the remote of the current branch
+func remoteBranch() (string, error) {
+	branch, err := core.GetCurrentBranch()
+	if err != nil || branch == "" || branch == "HEAD" {
+		return "", fmt.Errorf("no branch checked out")
+	}
+	upstream, err := core.GetBranchUpstream(branch, remoteName)
+	if err != nil {
+		return "", err
 	}
+	return upstream.Branch, nil
+}
+
+// warnUnpushed warns when commit is not on the remote, its page does not exist yet
+func warnUnpushed(commit string) {
+	if !core.IsPushed(commit, remoteName) {
+		log.Warnf("commit %.7s is not on %s yet, the link will not work until it is pushed", commit, remoteName)
+	}
+}
 
-	return "", fmt.Errorf("unsupported git URL format: %s", gitURL)
+// remoteForge returns the forge hosting remote, the forges config applies
+// when the config loads
+func remoteForge(remote string) (core.Forge, error) {
+	repo, err := core.GetRemoteIdentity(remote)
+	if err != nil {
+		return nil, err
+	}
+	config, err := core.LoadConfig()
+	if err != nil {
+		log.Debugf("detecting the forge from host %s, the config did not load: %v", repo.Host, err)
+		return core.DetectForge(repo)
+	}
+	return config.Forge(repo)
 }
 
 // openBrowser opens the specified URL in the default browser
@@ -118,4 +211,10 @@ func openBrowser(url string) error {
 func init() {
 	rootCmd.AddCommand(openCmd)
 	openCmd.Flags().StringVarP(&remoteName, "remote", "r", "origin", "Remote name to open (default: origin)")
+	openCmd.Flags().BoolVarP(&openBranch, "branch", "b", false, "Open the current branch, or the file on it instead of at the current commit")
+	openCmd.Flags().BoolVar(&openBlame, "blame", false, "Open the blame of the file")
+	openCmd.Flags().BoolVar(&openIssues, "issues", false, "Open the issues")
+	openCmd.Flags().BoolVar(&openActions, "actions", false, "Open the CI runs: GitHub Actions, GitLab pipelines, builds...")
+	openCmd.MarkFlagsMutuallyExclusive("issues", "actions", "branch")
+	openCmd.MarkFlagsMutuallyExclusive("issues", "actions", "blame")
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// pairCmd represents the pair command
+var pairCmd = &cobra.Command{
+	Use:   "pair",
+	Short: "Credit the people you pair or mob with on every commit",
+	Long: `Set the active pair of the repository so every commit gets a
+Co-authored-by trailer for each of them, from zgit commit and from the
+commit-msg hook.
+
+The pair is a list of aliases of the coauthors config, or "Name <email>"
+values, stored in the repository git config as zgit.pair.
+
+  global:
+    coauthors:
+      alice:
+        name: Alice Smith
+        email: alice@example.com
+
+Examples:
+  zgit pair                  # Show the active pair
+  zgit pair set alice bob    # Pair with alice and bob
+  zgit pair clear            # Stop pairing`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printPair()
+	},
+}
+
+// pairSetCmd represents the pair set command
+var pairSetCmd = &cobra.Command{
+	Use:   "set <alias>...",
+	Short: "Set the co-authors of the active pair",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig()
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+		// Check the aliases now rather than on the next commit
+		settings, err := currentSettings(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := settings.ResolveCoAuthors(args); err != nil {
+			log.Fatal(err)
+		}
+		if err := core.SetPair(args); err != nil {
+			log.Fatal(err)
+		}
+		printPair()
+	},
+}
+
+// pairClearCmd represents the pair clear command
+var pairClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Stop pairing, commits get no Co-authored-by trailers",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := core.ClearPair(); err != nil {
+			log.Fatal(err)
+		}
+		printPair()
+	},
+}
+
+// currentSettings returns the effective settings of the current repository
+func currentSettings(config *core.Config) (core.GlobalConfig, error) {
+	repo, err := core.GetRepoIdentity()
+	if err != nil {
+		return core.GlobalConfig{}, fmt.Errorf("failed to get repository name: %w", err)
+	}
+	return config.Settings(repo), nil
+}
+
+// printPair prints the co-authors of the active pair
+func printPair() {
+	aliases := core.GetPair()
+	if len(aliases) == 0 {
+		fmt.Println("Not pairing")
+		return
+	}
+	config, err := core.LoadConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	settings, err := currentSettings(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Pairing with:")
+	for _, alias := range aliases {
+		coAuthors, err := settings.ResolveCoAuthors([]string{alias})
+		if err != nil {
+			fmt.Printf("  %-12s %v\n", alias, err)
+			continue
+		}
+		fmt.Printf("  %-12s %s\n", alias, coAuthors[0])
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(pairCmd)
+	pairCmd.AddCommand(pairSetCmd, pairClearCmd)
+}
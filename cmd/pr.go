@@ -4,9 +4,6 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"fmt"
-	"os/exec"
-	"strings"
 	"zhaojunlucky/zgit/core"
 
 	log "github.com/sirupsen/logrus"
@@ -19,11 +16,12 @@ var prBaseBranch string
 // prCmd represents the pr command
 var prCmd = &cobra.Command{
 	Use:   "pr",
-	Short: "Open GitHub pull request creation page",
-	Long: `Open the GitHub pull request creation page in your default web browser.
+	Short: "Open the pull request creation page",
+	Long: `Open the pull request, or merge request, creation page in your default web browser.
 
-This command opens the compare URL to create a PR from the current branch
-to the base branch (default branch by default).
+This command opens the page to create a PR from the current branch to the
+base branch (default branch by default) on GitHub, GitLab, Bitbucket, Azure
+DevOps or Gitea, see zgit open for the forge detection.
 
 Examples:
   zgit pr                    # Compare current branch with default branch
@@ -38,30 +36,30 @@ Examples:
 		}
 		log.Infof("current branch: %s", currentBranch)
 
-		// Get remote URL
-		remoteURL, err := getRemoteURL(prRemoteName)
+		// Get the forge hosting the remote
+		forge, err := remoteForge(prRemoteName)
 		if err != nil {
-			log.Fatalf("failed to get remote URL: %v", err)
-		}
-
-		// Parse to web URL
-		webURL, err := parseGitURLToWeb(remoteURL)
-		if err != nil {
-			log.Fatalf("failed to parse git URL: %v", err)
+			log.Fatalf("failed to get the forge of %s: %v", prRemoteName, err)
 		}
 
 		// Get base branch (default branch if not specified)
 		baseBranch := prBaseBranch
 		if baseBranch == "" {
-			baseBranch, err = getDefaultBranch(prRemoteName)
+			baseBranch, err = core.GetDefaultBranch(prRemoteName)
 			if err != nil {
 				log.Fatalf("failed to get default branch: %v", err)
 			}
 		}
 		log.Infof("base branch: %s", baseBranch)
 
-		// Build PR URL: https://github.com/owner/repo/compare/base...head
-		prURL := fmt.Sprintf("%s/compare/%s...%s", webURL, baseBranch, currentBranch)
+		// The branch can have another name on the remote
+		upstream, err := core.GetBranchUpstream(currentBranch, prRemoteName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Build PR URL, e.g. https://github.com/owner/repo/compare/base...head?expand=1
+		prURL := forge.NewPullRequestURL(baseBranch, upstream.Branch)
 		log.Infof("opening %s", prURL)
 
 		if err := openBrowser(prURL); err != nil {
@@ -70,33 +68,6 @@ Examples:
 	},
 }
 
-// getDefaultBranch gets the default branch for the remote
-func getDefaultBranch(remote string) (string, error) {
-	// Try to get the default branch from remote HEAD
-	cmd := exec.Command("git", "symbolic-ref", fmt.Sprintf("refs/remotes/%s/HEAD", remote))
-	output, err := cmd.Output()
-	if err == nil {
-		// refs/remotes/origin/HEAD -> refs/remotes/origin/main
-		ref := strings.TrimSpace(string(output))
-		// Extract branch name from refs/remotes/origin/main
-		parts := strings.Split(ref, "/")
-		if len(parts) > 0 {
-			return parts[len(parts)-1], nil
-		}
-	}
-
-	// Fallback: try common default branch names
-	commonDefaults := []string{"main", "master"}
-	for _, branch := range commonDefaults {
-		cmd := exec.Command("git", "rev-parse", "--verify", fmt.Sprintf("refs/remotes/%s/%s", remote, branch))
-		if err := cmd.Run(); err == nil {
-			return branch, nil
-		}
-	}
-
-	return "", fmt.Errorf("could not determine default branch for remote '%s'", remote)
-}
-
 func init() {
 	rootCmd.AddCommand(prCmd)
 	prCmd.Flags().StringVarP(&prRemoteName, "remote", "r", "origin", "Remote name (default: origin)")
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	"golang.org/x/term"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// promptLine prints the prompt and returns the trimmed line typed by the user
+func promptLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	response, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read user input: %w", err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// confirm asks a yes/no question, an empty answer returns defaultYes
+func confirm(prompt string, defaultYes bool) (bool, error) {
+	if defaultYes {
+		prompt += " (Y/n): "
+	} else {
+		prompt += " (y/N): "
+	}
+	response, err := promptLine(prompt)
+	if err != nil {
+		return false, err
+	}
+	response = strings.ToLower(response)
+	if response == "" {
+		return defaultYes, nil
+	}
+	return response == "y" || response == "yes", nil
+}
+
+// isInteractive reports whether stdin is a terminal the user can answer prompts on
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// promptTicket asks for the tickets of a branch without one until they all match ticketPattern
+func promptTicket(branch string, ticketPattern *regexp.Regexp) ([]string, error) {
+	for {
+		answer, err := promptLine(fmt.Sprintf("No ticket found for branch %s, enter tickets separated by commas (empty to abort): ", branch))
+		if err != nil || answer == "" {
+			return nil, err
+		}
+		tickets := core.SplitTickets(answer)
+		invalid := ""
+		for _, ticket := range tickets {
+			if ticketPattern != nil && !ticketPattern.MatchString(ticket) {
+				invalid = ticket
+				break
+			}
+		}
+		if invalid == "" {
+			return tickets, nil
+		}
+		fmt.Printf("%s does not match the ticket pattern %s\n", invalid, ticketPattern)
+	}
+}
+
+// ticketOptions returns the ticket options of a command, the prompt is only
+// offered when a user can answer it
+func ticketOptions(tickets []string) core.TicketOptions {
+	opts := core.TicketOptions{Tickets: tickets}
+	if isInteractive() {
+		opts.Prompt = promptTicket
+	}
+	return opts
+}
+
+// pickOption lists options and returns the one chosen by number or name,
+// an empty answer returns def and "-" returns "". With allowOther any typed
+// value is accepted.
+func pickOption(prompt string, options []string, def string, allowOther bool) (string, error) {
+	for i, option := range options {
+		fmt.Printf("  %2d) %s\n", i+1, option)
+	}
+	if def != "" {
+		prompt += fmt.Sprintf(" [%s]", def)
+	}
+	for {
+		answer, err := promptLine(prompt + ": ")
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return def, nil
+		}
+		if answer == "-" {
+			return "", nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		if allowOther || slices.Contains(options, answer) {
+			return answer, nil
+		}
+		fmt.Printf("%s is not one of the options\n", answer)
+	}
+}
+
+// pickConventionalHeader asks for the type and scope of a conventional commit,
+// the scope defaults to the one suggested from the staged files
+func pickConventionalHeader(conventional core.ConventionalConfig, suggestedScope string) (core.ConventionalHeader, error) {
+	var header core.ConventionalHeader
+	commitType, err := pickOption("Commit type", conventional.AllowedTypes(), "", false)
+	if err != nil {
+		return header, err
+	}
+	if commitType == "" {
+		return header, errors.New("no commit type chosen")
+	}
+	header.Type = commitType
+
+	header.Scope, err = pickOption("Commit scope (- for none)", conventional.Scopes, suggestedScope, len(conventional.Scopes) == 0)
+	return header, err
+}
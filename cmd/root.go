@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 	"zhaojunlucky/zgit/core"
 
 	log "github.com/sirupsen/logrus"
@@ -41,8 +42,14 @@ Configuration:
 
 Commands:
   commit      - Commit with automatic ticket prefix
-  force-pull  - Force pull by recreating local branch from origin
+  config      - Show, explain, validate and edit the configuration
+  force-pull  - Force pull by resetting the local branch to its upstream
+  hooks       - Install git hooks that add the ticket to every commit
   init        - Initialize zgit configuration
+  lint        - Check commit messages against the template and lint rules
+  pair        - Add Co-authored-by trailers for the people you pair with
+  start       - Create a ticket branch from the remote default branch
+  worktree    - Create and clean up a worktree per ticket
   version     - Show version information
   
   Any other command will be passed directly to git`,
@@ -129,9 +136,25 @@ func Execute() {
 	}
 }
 
+// passthroughArgs returns the arguments of a command that passes unknown
+// subcommands to git, without the -C flag already applied by zgit
+func passthroughArgs(args []string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-C" || args[i] == "--repo-dir":
+			i++
+		case strings.HasPrefix(args[i], "--repo-dir="):
+		default:
+			result = append(result, args[i])
+		}
+	}
+	return result
+}
+
 // isKnownCommand checks if a command is a known zgit subcommand
 func isKnownCommand(cmd string) bool {
-	knownCommands := []string{"commit", "force-pull", "init", "version", "completion", "help", "open", "pr"}
+	knownCommands := []string{"commit", "config", "force-pull", "hooks", "init", "lint", "pair", "start", "version", "worktree", "completion", "help", "open", "pr"}
 	for _, known := range knownCommands {
 		if cmd == known {
 			return true
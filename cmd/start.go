@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var startRemote string
+var startBase string
+var startPush bool
+var startDryRun bool
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Use:   "start <ticket> [description]",
+	Short: "Create a ticket branch from the fresh remote default branch",
+	Long: `Create a branch for a ticket, named by the start.branch template, from the
+freshly fetched default branch of the remote, and set its upstream to a
+branch of the same name on the remote.
+
+The template gets {{.Ticket}}, {{.Description}}, {{.Slug}} (the description
+in lower case with words joined by "-"), {{.User.Login}} (git config
+zgit.user, or the part of user.email before the @), {{.User.Name}},
+{{.User.Email}} and {{.Date}}:
+
+  global:
+    start:
+      branch: "usr/{{.User.Login}}/{{.Ticket}}{{with .Slug}}-{{.}}{{end}}"
+
+The branch name must match a branch pattern that finds the ticket again, so
+commits on the branch get the ticket.
+
+Examples:
+  zgit start JIRA-123 "short description"   # usr/ann/JIRA-123-short-description
+  zgit start JIRA-123 --base develop         # Branch off develop
+  zgit start JIRA-123 fix login --push       # Push the branch right away
+  zgit start JIRA-123 fix login --dry-run    # Only print the branch name`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		branch := nameTicketBranch(args[0], strings.Join(args[1:], " "))
+		if startDryRun {
+			fmt.Println(branch.name)
+			return
+		}
+		branch.fetchBase()
+		if err := core.RunGitCommand("switch", "--no-track", "-c", branch.name, branch.base.TrackingRef()); err != nil {
+			log.Fatalf("failed to create branch %s: %v", branch.name, err)
+		}
+		branch.track()
+	},
+}
+
+// ticketBranch is a new ticket branch and the remote branch it starts from
+type ticketBranch struct {
+	name string
+	repo *core.RepoIdentity
+	base core.Upstream
+}
+
+// nameTicketBranch names the branch of ticket with the start.branch template
+// and checks that the branch patterns find the ticket in it
+func nameTicketBranch(ticket, description string) *ticketBranch {
+	config, err := core.LoadConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	var repo *core.RepoIdentity
+	if startRemote != "" {
+		repo, err = core.GetRemoteIdentity(startRemote)
+	} else {
+		repo, err = core.GetRepoIdentity()
+	}
+	if err != nil {
+		log.Fatalf("failed to get repository name: %v", err)
+	}
+
+	name, err := config.RenderBranchName(config.Settings(repo), core.NewBranchData(ticket, description))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.CheckStartBranch(repo, name, ticket); err != nil {
+		log.Fatal(err)
+	}
+	return &ticketBranch{name: name, repo: repo}
+}
+
+// fetchBase fetches the branch to start from, the remote default branch unless --base is given
+func (b *ticketBranch) fetchBase() {
+	if core.BranchExists(b.name) {
+		log.Fatalf("branch %s already exists", b.name)
+	}
+	b.base = core.Upstream{Remote: b.repo.Remote, Branch: startBase}
+	if b.base.Branch == "" {
+		var err error
+		b.base.Branch, err = core.GetRemoteDefaultBranch(b.repo.Remote)
+		if err != nil {
+			log.Fatalf("failed to get default branch: %v", err)
+		}
+	}
+	log.Infof("fetching %s", b.base)
+	if err := core.FetchUpstream(b.base); err != nil {
+		log.Fatalf("failed to fetch %s: %v", b.base, err)
+	}
+}
+
+// track pushes the new branch with --push, or sets its upstream to the branch
+// of the same name on the remote
+func (b *ticketBranch) track() {
+	if startPush {
+		if err := core.RunGitCommand("push", "--set-upstream", b.repo.Remote, b.name); err != nil {
+			log.Fatalf("failed to push branch %s: %v", b.name, err)
+		}
+		return
+	}
+	// The remote branch does not exist yet, so set the upstream in the config
+	// the way git push --set-upstream would
+	if err := core.SetBranchUpstream(b.name, b.repo.Remote, b.name); err != nil {
+		log.Fatalf("failed to set the upstream of %s: %v", b.name, err)
+	}
+	log.Infof("created branch %s from %s, upstream %s/%s", b.name, b.base, b.repo.Remote, b.name)
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+	startCmd.Flags().StringVarP(&startRemote, "remote", "r", "", "Remote to branch from and push to (default: the remote of the repository)")
+	startCmd.Flags().StringVarP(&startBase, "base", "b", "", "Branch to start from (default: remote's default branch)")
+	startCmd.Flags().BoolVar(&startPush, "push", false, "Push the new branch and set its upstream")
+	startCmd.Flags().BoolVar(&startDryRun, "dry-run", false, "Only print the branch name")
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"zhaojunlucky/zgit/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var worktreePath string
+var worktreeDryRun bool
+
+// Statuses of a worktree in the prune-merged summary
+const (
+	statusRemoved     = "removed"
+	statusWouldRemove = "would remove"
+)
+
+// worktreeCmd represents the worktree command
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree",
+	Short: "Create and clean up a worktree per ticket",
+	Long: `Create a linked worktree per ticket and remove the ones whose branch was
+merged. Any other worktree command is passed to git, e.g. zgit worktree list.
+
+Examples:
+  zgit worktree new JIRA-123 "short description"  # ../<repo>-JIRA-123 on a new ticket branch
+  zgit worktree prune-merged --dry-run            # Show which worktrees would be removed
+  zgit worktree prune-merged                      # Remove the worktrees of merged tickets
+  zgit worktree list                              # Run git worktree list`,
+	// Flags belong to the git worktree command passed through
+	DisableFlagParsing: true,
+	Args:               cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		args = passthroughArgs(args)
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			_ = cmd.Help()
+			return
+		}
+		if err := core.RunGitCommand(append([]string{"worktree"}, args...)...); err != nil {
+			log.Fatalf("git command failed: %v", err)
+		}
+	},
+}
+
+// worktreeNewCmd represents the worktree new command
+var worktreeNewCmd = &cobra.Command{
+	Use:   "new <ticket> [description]",
+	Short: "Create a worktree on a new ticket branch",
+	Long: `Create a linked worktree on a new ticket branch, named by the start.branch
+template and started from the freshly fetched remote default branch like
+zgit start does. The worktree goes next to the main worktree, named after it
+and the ticket, unless --path is given.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ticket := args[0]
+		branch := nameTicketBranch(ticket, strings.Join(args[1:], " "))
+		path := worktreePath
+		if path == "" {
+			var err error
+			path, err = core.DefaultWorktreePath(ticket)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if startDryRun {
+			fmt.Printf("%s %s\n", branch.name, path)
+			return
+		}
+		branch.fetchBase()
+		if err := core.RunGitCommand("worktree", "add", "--no-track", "-b", branch.name, path, branch.base.TrackingRef()); err != nil {
+			log.Fatalf("failed to create worktree %s: %v", path, err)
+		}
+		branch.track()
+		log.Infof("created worktree %s", path)
+	},
+}
+
+// worktreePruneMergedCmd represents the worktree prune-merged command
+var worktreePruneMergedCmd = &cobra.Command{
+	Use:   "prune-merged",
+	Short: "Remove the worktrees and branches of merged tickets",
+	Long: `Remove the linked worktrees whose ticket branch was merged into the
+freshly fetched remote default branch, or --base, and delete their branches.
+
+Only worktrees on a branch the branch patterns find a ticket in are
+considered. Worktrees that are locked, have uncommitted changes or untracked
+files, or whose branch has no commits of its own yet are kept. The tip of a
+deleted branch is saved as refs/zgit/backup/<branch>/<timestamp>.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig()
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+		repo, err := core.GetRepoIdentity()
+		if err != nil {
+			log.Fatalf("failed to get repository name: %v", err)
+		}
+		base := core.Upstream{Remote: repo.Remote, Branch: startBase}
+		if base.Branch == "" {
+			base.Branch, err = core.GetRemoteDefaultBranch(repo.Remote)
+			if err != nil {
+				log.Fatalf("failed to get default branch: %v", err)
+			}
+		}
+		if err := core.FetchUpstream(base); err != nil {
+			log.Fatalf("failed to fetch %s: %v", base, err)
+		}
+
+		worktrees, err := core.ListWorktrees()
+		if err != nil {
+			log.Fatal(err)
+		}
+		current, _ := core.GetRepoRoot()
+		var results []summaryRow
+		for _, worktree := range worktrees {
+			if worktree.Main {
+				continue
+			}
+			status, detail, err := pruneMergedWorktree(config, repo, worktree, base, current)
+			if err != nil {
+				status, detail = statusFailed, err.Error()
+			}
+			results = append(results, summaryRow{worktree.Path, status, detail})
+		}
+		if len(results) == 0 {
+			fmt.Println("No linked worktrees")
+			return
+		}
+		if failed := printSummary("Prune summary:", results); failed > 0 {
+			log.Fatalf("pruning failed for %d of %d worktrees", failed, len(results))
+		}
+	},
+}
+
+// pruneMergedWorktree removes worktree and its branch if the branch is a ticket
+// branch merged into base, and returns the status and a detail for the summary
+func pruneMergedWorktree(config *core.Config, repo *core.RepoIdentity, worktree core.Worktree, base core.Upstream, current string) (string, string, error) {
+	switch {
+	case worktree.Branch == "":
+		return statusSkipped, "HEAD is detached", nil
+	case worktree.Locked:
+		return statusSkipped, "locked", nil
+	case worktree.Prunable:
+		return statusSkipped, "directory is missing, run git worktree prune", nil
+	case worktree.Path == current:
+		return statusSkipped, "current worktree", nil
+	}
+	if _, err := config.MatchBranch(repo, worktree.Branch); errors.Is(err, core.ErrTicketNotFound) {
+		return statusSkipped, fmt.Sprintf("%s is not a ticket branch", worktree.Branch), nil
+	} else if err != nil {
+		return "", "", err
+	}
+	// A new branch is an ancestor of the base too
+	if !core.BranchMoved(worktree.Branch) {
+		return statusSkipped, fmt.Sprintf("%s has no commits yet", worktree.Branch), nil
+	}
+	if !core.IsAncestor(worktree.Head, base.TrackingRef()) {
+		return statusSkipped, fmt.Sprintf("%s is not merged into %s", worktree.Branch, base), nil
+	}
+	changed, err := worktree.HasChanges()
+	if err != nil {
+		return "", "", err
+	}
+	if changed {
+		return statusSkipped, "has uncommitted changes or untracked files", nil
+	}
+	if worktreeDryRun {
+		return statusWouldRemove, fmt.Sprintf("%s is merged into %s", worktree.Branch, base), nil
+	}
+
+	backup, err := core.BackupBranch(worktree.Branch)
+	if err != nil {
+		return "", "", err
+	}
+	if output, err := exec.Command("git", "worktree", "remove", worktree.Path).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to remove worktree: %s", strings.TrimSpace(string(output)))
+	}
+	// git branch -d would check the merge against HEAD instead of the base
+	if output, err := exec.Command("git", "branch", "-D", worktree.Branch).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("removed the worktree but not branch %s: %s", worktree.Branch, strings.TrimSpace(string(output)))
+	}
+	return statusRemoved, fmt.Sprintf("%s is merged into %s, backup %s", worktree.Branch, base, backup), nil
+}
+
+func init() {
+	rootCmd.AddCommand(worktreeCmd)
+	worktreeCmd.AddCommand(worktreeNewCmd, worktreePruneMergedCmd)
+	worktreeNewCmd.Flags().StringVarP(&startRemote, "remote", "r", "", "Remote to branch from and push to (default: the remote of the repository)")
+	worktreeNewCmd.Flags().StringVarP(&startBase, "base", "b", "", "Branch to start from (default: remote's default branch)")
+	worktreeNewCmd.Flags().BoolVar(&startPush, "push", false, "Push the new branch and set its upstream")
+	worktreeNewCmd.Flags().BoolVar(&startDryRun, "dry-run", false, "Only print the branch name and the worktree path")
+	worktreeNewCmd.Flags().StringVarP(&worktreePath, "path", "p", "", "Directory of the worktree (default: next to the main worktree)")
+	worktreePruneMergedCmd.Flags().StringVarP(&startBase, "base", "b", "", "Branch the ticket branches are merged into (default: remote's default branch)")
+	worktreePruneMergedCmd.Flags().BoolVar(&worktreeDryRun, "dry-run", false, "Only show which worktrees would be removed")
+}
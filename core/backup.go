@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// backupRefPrefix is where force-pull keeps the old tips of branches
+const backupRefPrefix = "refs/zgit/backup/"
+
+// backupTimeFormat sorts backups of a branch by time and is valid in a ref name
+const backupTimeFormat = "20060102-150405"
+
+// BackupBranch saves the tip of branch as refs/zgit/backup/<branch>/<timestamp>
+// and returns the backup ref
+func BackupBranch(branch string) (string, error) {
+	commit, err := ResolveCommit("refs/heads/" + branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve branch %s: %w", branch, err)
+	}
+	ref := backupRefPrefix + branch + "/" + time.Now().Format(backupTimeFormat)
+	// Keep an earlier backup made in the same second unless it is the same commit
+	if existing, err := ResolveCommit(ref); err == nil && existing != commit {
+		ref += fmt.Sprintf("-%09d", time.Now().Nanosecond())
+	}
+	if err := exec.Command("git", "update-ref", "-m", "zgit: backup "+branch, ref, commit).Run(); err != nil {
+		return "", fmt.Errorf("failed to create backup ref %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// backupNameRegex matches the last component of a backup ref, backupTimeFormat
+// with the nanoseconds added on a collision
+var backupNameRegex = regexp.MustCompile(`^\d{8}-\d{6}(?:-\d{9})?$`)
+
+// ListBackups returns the backup refs of branch, newest first
+func ListBackups(branch string) ([]string, error) {
+	prefix := backupRefPrefix + branch + "/"
+	output, err := exec.Command("git", "for-each-ref", "--sort=-refname", "--format=%(refname)", prefix).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups of %s: %w", branch, err)
+	}
+	var backups []string
+	for _, ref := range strings.Fields(string(output)) {
+		// The prefix also lists the backups of branches below this one, e.g. feat/x for feat
+		if backupNameRegex.MatchString(strings.TrimPrefix(ref, prefix)) {
+			backups = append(backups, ref)
+		}
+	}
+	return backups, nil
+}
+
+// DeleteRef deletes ref, e.g. a backup that was restored
+func DeleteRef(ref string) error {
+	return exec.Command("git", "update-ref", "-d", ref).Run()
+}
+
+// ResolveCommit returns the commit hash ref points to
+func ResolveCommit(ref string) (string, error) {
+	output, err := exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// HasUncommittedChanges reports whether tracked files have staged or unstaged changes
+func HasUncommittedChanges() (bool, error) {
+	output, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the worktree status: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// UnpushedCommits returns the commits of branch that no remote-tracking
+// branch contains, newest first, as "hash subject" lines. Commits reachable
+// from pushed, such as the tip of a remote branch before it was force-pushed,
+// are not counted.
+func UnpushedCommits(branch string, pushed ...string) ([]string, error) {
+	args := append([]string{"log", "--format=%h %s", "refs/heads/" + branch, "--not", "--remotes"}, pushed...)
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the unpushed commits of %s: %w", branch, err)
+	}
+	return strings.FieldsFunc(string(output), func(r rune) bool { return r == '\n' }), nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// LocalBranch is a local branch and where it is checked out
+type LocalBranch struct {
+	Name string
+	// Tip is the commit the branch points to
+	Tip string
+	// Worktree is the worktree the branch is checked out in, empty if none
+	Worktree string
+	// Upstream is the ref the branch tracks, e.g. refs/remotes/origin/main, empty if none
+	Upstream string
+}
+
+// ListLocalBranches returns the local branches sorted by name
+func ListLocalBranches() ([]LocalBranch, error) {
+	output, err := exec.Command("git", "for-each-ref", "--format=%(refname)%00%(objectname)%00%(worktreepath)%00%(upstream)", "refs/heads/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	var branches []LocalBranch
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		branches = append(branches, LocalBranch{
+			Name:     strings.TrimPrefix(fields[0], "refs/heads/"),
+			Tip:      fields[1],
+			Worktree: fields[2],
+			Upstream: fields[3],
+		})
+	}
+	return branches, nil
+}
+
+// TracksRemote reports whether the branch tracks a remote branch rather than a local one
+func (b LocalBranch) TracksRemote() bool {
+	return strings.HasPrefix(b.Upstream, "refs/remotes/")
+}
+
+// GetLocalBranch returns the local branch called name
+func GetLocalBranch(name string) (LocalBranch, error) {
+	branches, err := ListLocalBranches()
+	if err != nil {
+		return LocalBranch{}, err
+	}
+	for _, branch := range branches {
+		if branch.Name == name {
+			return branch, nil
+		}
+	}
+	return LocalBranch{}, fmt.Errorf("branch %s does not exist", name)
+}
+
+// IsAncestor reports whether commit is an ancestor of, or the same as, descendant
+func IsAncestor(commit, descendant string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", commit, descendant).Run() == nil
+}
+
+// UpdateBranch moves branch from oldTip to newTip, it fails without changing
+// anything if the branch no longer points to oldTip
+func UpdateBranch(branch, newTip, oldTip, reason string) error {
+	output, err := exec.Command("git", "update-ref", "-m", reason, "refs/heads/"+branch, newTip, oldTip).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to update branch %s: %s", branch, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// shortValueFlags are the short git commit flags that take a value,
+// either attached (-mmsg) or as the next argument (-m msg)
+const shortValueFlags = "mFcCt"
+
+// shortOptionalFlags are the short git commit flags with an optional attached value, e.g. -Skeyid
+const shortOptionalFlags = "Su"
+
+// longValueFlags are the long git commit flags that take the next argument
+// as value when it is not attached with "="
+var longValueFlags = map[string]bool{
+	"--message":            true,
+	"--file":               true,
+	"--reuse-message":      true,
+	"--reedit-message":     true,
+	"--template":           true,
+	"--author":             true,
+	"--date":               true,
+	"--cleanup":            true,
+	"--fixup":              true,
+	"--squash":             true,
+	"--trailer":            true,
+	"--pathspec-from-file": true,
+	"--ticket":             true,
+	"--type":               true,
+	"--scope":              true,
+	"--co-author":          true,
+}
+
+// CommitArgs is a git commit command line split into its message sources
+// and the arguments that are passed to git unchanged
+type CommitArgs struct {
+	// Messages are the -m paragraphs in order
+	Messages []string
+	// File is the -F message file, "-" reads stdin
+	File string
+	// ReuseCommit is the -C commit whose message and authorship are reused
+	ReuseCommit string
+	// ReeditCommit is the -c commit, like ReuseCommit but the message is edited
+	ReeditCommit string
+	// Template is the -t file that prefills the editor
+	Template string
+	Edit     bool
+	NoEdit   bool
+	Amend    bool
+	// Fixup is set for --fixup and --squash, git builds those messages itself
+	Fixup    bool
+	NoTicket bool
+	// Tickets are the --ticket values used instead of the tickets from the branch name
+	Tickets []string
+	// Type and Scope are the Conventional Commits --type and --scope
+	Type  string
+	Scope string
+	// CoAuthors are the --co-author aliases or "Name <email>" values
+	CoAuthors []string
+	// Cleanup is the --cleanup mode, empty for git's default
+	Cleanup string
+	// Other are the remaining arguments, passed to git as they are
+	Other []string
+}
+
+// ParseCommitArgs parses a git commit command line
+func ParseCommitArgs(args []string) (*CommitArgs, error) {
+	commitArgs := &CommitArgs{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			commitArgs.Other = append(commitArgs.Other, args[i:]...)
+			return commitArgs, nil
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg, "=")
+			if !hasValue && longValueFlags[name] {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("option %s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			if !commitArgs.setLong(name, value) {
+				if hasValue || !longValueFlags[name] {
+					commitArgs.Other = append(commitArgs.Other, arg)
+				} else {
+					commitArgs.Other = append(commitArgs.Other, name, value)
+				}
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			var flags []byte
+			for j := 1; j < len(arg); j++ {
+				flag := arg[j]
+				if strings.IndexByte(shortOptionalFlags, flag) >= 0 {
+					// The rest of the cluster is the optional value
+					commitArgs.Other = append(commitArgs.Other, "-"+arg[j:])
+					break
+				}
+				if flag == 'e' {
+					// zgit runs the editor itself
+					commitArgs.Edit = true
+					continue
+				}
+				if strings.IndexByte(shortValueFlags, flag) < 0 {
+					flags = append(flags, flag)
+					continue
+				}
+
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						return nil, fmt.Errorf("switch -%c requires a value", flag)
+					}
+					i++
+					value = args[i]
+				}
+				commitArgs.setShortValue(flag, value)
+				break
+			}
+			if len(flags) > 0 {
+				commitArgs.Other = append(commitArgs.Other, "-"+string(flags))
+			}
+		default:
+			commitArgs.Other = append(commitArgs.Other, arg)
+		}
+	}
+	return commitArgs, nil
+}
+
+// setLong records a long message option, it returns false for options passed to git
+func (a *CommitArgs) setLong(name, value string) bool {
+	switch name {
+	case "--message":
+		a.Messages = append(a.Messages, value)
+	case "--file":
+		a.File = value
+	case "--reuse-message":
+		a.ReuseCommit = value
+	case "--reedit-message":
+		a.ReeditCommit = value
+	case "--template":
+		a.Template = value
+	case "--edit":
+		a.Edit = true
+	case "--no-edit":
+		a.NoEdit = true
+	case "--no-ticket":
+		a.NoTicket = true
+	case "--ticket":
+		a.Tickets = append(a.Tickets, SplitTickets(value)...)
+	case "--type":
+		a.Type = value
+	case "--scope":
+		a.Scope = value
+	case "--co-author":
+		a.CoAuthors = append(a.CoAuthors, strings.Split(value, ",")...)
+	case "--amend":
+		a.Amend = true
+		return false
+	case "--fixup", "--squash":
+		a.Fixup = true
+		return false
+	case "--cleanup":
+		a.Cleanup = value
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// setShortValue records a short message option
+func (a *CommitArgs) setShortValue(flag byte, value string) {
+	switch flag {
+	case 'm':
+		a.Messages = append(a.Messages, value)
+	case 'F':
+		a.File = value
+	case 'C':
+		a.ReuseCommit = value
+	case 'c':
+		a.ReeditCommit = value
+	case 't':
+		a.Template = value
+	}
+}
+
+// GitArgs rebuilds the git commit arguments without the zgit options
+// --no-ticket, --ticket, --type, --scope and --co-author, for commits passed
+// to git as they are
+func (a *CommitArgs) GitArgs() []string {
+	var args []string
+	for _, message := range a.Messages {
+		args = append(args, "-m", message)
+	}
+	for _, option := range [][2]string{{"-F", a.File}, {"-C", a.ReuseCommit}, {"-c", a.ReeditCommit}, {"-t", a.Template}} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	if a.Edit {
+		args = append(args, "--edit")
+	}
+	if a.NoEdit {
+		args = append(args, "--no-edit")
+	}
+	return append(args, a.Other...)
+}
+
+// Message assembles the commit message from its sources like git does and
+// reports whether git would open the editor for it
+func (a *CommitArgs) Message() (string, bool, error) {
+	sources := 0
+	for _, set := range []bool{len(a.Messages) > 0, a.File != "", a.ReuseCommit != "", a.ReeditCommit != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", false, errors.New("only one of -m, -F, -c and -C can be used")
+	}
+
+	switch {
+	case len(a.Messages) > 0:
+		return strings.Join(a.Messages, "\n\n"), a.Edit, nil
+	case a.File != "":
+		message, err := ReadMessageFile(a.File)
+		return message, a.Edit, err
+	case a.ReuseCommit != "":
+		message, err := GetCommitMessage(a.ReuseCommit)
+		return message, a.Edit, err
+	case a.ReeditCommit != "":
+		message, err := GetCommitMessage(a.ReeditCommit)
+		return message, !a.NoEdit, err
+	case a.Amend:
+		message, err := GetCommitMessage("HEAD")
+		return message, !a.NoEdit, err
+	}
+
+	// Editor commit: prefill like git does with the merge message or the template
+	for _, name := range []string{"MERGE_MSG", "SQUASH_MSG"} {
+		if path, err := GetGitPath(name); err == nil {
+			if data, err := os.ReadFile(path); err == nil {
+				return string(data), !a.NoEdit, nil
+			}
+		}
+	}
+	templateFile := a.Template
+	if templateFile == "" {
+		templateFile, _ = GetGitConfig("commit.template")
+	}
+	if templateFile != "" {
+		message, err := ReadMessageFile(expandHome(templateFile))
+		return message, true, err
+	}
+	return "", true, nil
+}
+
+// AuthorArgs returns the --author and --date arguments that keep the
+// authorship of the -c or -C commit when its message is passed as a file
+func (a *CommitArgs) AuthorArgs() ([]string, error) {
+	commit := a.ReuseCommit
+	if commit == "" {
+		commit = a.ReeditCommit
+	}
+	if commit == "" {
+		return nil, nil
+	}
+	for _, arg := range a.Other {
+		if arg == "--reset-author" {
+			return nil, nil
+		}
+	}
+
+	output, err := exec.Command("git", "log", "-1", "--format=%an <%ae>%n%aI", commit).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author of %s: %w", commit, err)
+	}
+	author, date, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return []string{"--author", author, "--date", date}, nil
+}
+
+// ReadMessageFile reads a message file, "-" reads stdin
+func ReadMessageFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read message file %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// expandHome expands a leading ~/ like git does for commit.template
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return homeDir + path[1:]
+}
+
+// GetCommitMessage returns the full message of a commit
+func GetCommitMessage(commit string) (string, error) {
+	output, err := exec.Command("git", "log", "-1", "--format=%B", commit).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read message of %s: %w", commit, err)
+	}
+	return string(output), nil
+}
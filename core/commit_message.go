@@ -0,0 +1,445 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CommitData is the data available in the commit message template
+type CommitData struct {
+	// Ticket is Tickets joined with commit.joiner
+	Ticket string
+	// Tickets are all tickets of the branch, e.g. {{range .Tickets}}[{{.}}]{{end}}
+	Tickets []string
+	Message string
+	// Type, Scope and Breaking are the Conventional Commits header, e.g.
+	// {{.Type}}{{with .Scope}}({{.}}){{end}}{{if .Breaking}}!{{end}}
+	Type     string
+	Scope    string
+	Breaking bool
+	Branch   string
+	Repo     CommitRepo
+	// Groups are all named capture groups of the matched branch pattern, e.g. {{.Groups.scope}}
+	Groups map[string]string
+	User   CommitUser
+	// Files are the staged file paths relative to the repository root
+	Files []string
+	Date  time.Time
+}
+
+// CommitRepo describes the repository in the commit message template
+type CommitRepo struct {
+	Host     string
+	Owner    string
+	Name     string
+	FullName string
+}
+
+// CommitUser is the git user in the commit message template
+type CommitUser struct {
+	Name  string
+	Email string
+}
+
+// commitTemplateFuncs are the functions available in the commit message template
+var commitTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	// default returns value, or def when value is empty: {{default "core" .Groups.scope}}
+	"default": func(def string, value any) string {
+		if s, ok := value.(string); ok && s != "" {
+			return s
+		}
+		return def
+	},
+	// replace works in pipelines: {{.Message | replace "_" " "}}
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	// join works in pipelines: {{.Tickets | join "]["}}
+	"join": func(sep string, list []string) string {
+		return strings.Join(list, sep)
+	},
+	// truncate limits s to n characters: {{truncate 50 .Message}}
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if n < 0 || len(runes) <= n {
+			return s
+		}
+		return string(runes[:n])
+	},
+	// slug joins the lower-case words of s with "-": {{slug .Message}}
+	"slug": func(s string) string {
+		return Slugify(s, maxSlugLength)
+	},
+}
+
+// newCommitTemplate parses a commit message template with the zgit functions
+func newCommitTemplate(message string) (*template.Template, error) {
+	// A group of another pattern, or missing when the ticket came from a
+	// fallback, renders empty rather than as <no value>
+	return template.New("commit").Option("missingkey=zero").Funcs(commitTemplateFuncs).Parse(message)
+}
+
+// NewCommitData collects the template data for a commit on branch of repo
+func NewCommitData(match *BranchMatch, repo *RepoIdentity, branch, message string) *CommitData {
+	data := &CommitData{
+		Ticket:  match.Ticket,
+		Tickets: match.Tickets,
+		Message: message,
+		Branch:  branch,
+		Groups:  match.Groups,
+		Date:    time.Now(),
+	}
+	if repo != nil {
+		data.Repo = CommitRepo{
+			Host:     repo.Host,
+			Owner:    repo.Owner(),
+			Name:     repo.Repo,
+			FullName: repo.FullName(),
+		}
+	}
+	data.User.Name, _ = GetGitConfig("user.name")
+	data.User.Email, _ = GetGitConfig("user.email")
+	data.Files, _ = GetStagedFiles()
+	return data
+}
+
+// CommitContext holds everything needed to render commit messages for the current branch
+type CommitContext struct {
+	Config *Config
+	Repo   *RepoIdentity
+	Branch string
+	Match  *BranchMatch
+	// Header is the Conventional Commits type and scope of the commit
+	Header ConventionalHeader
+	// CoAuthors are credited with Co-authored-by trailers
+	CoAuthors []CoAuthor
+}
+
+// LoadCommitContext resolves the repository, branch, config and ticket of the current directory,
+// the ticket falls back to opts and the configured sources when the branch name has none
+func LoadCommitContext(opts TicketOptions) (*CommitContext, error) {
+	ctx, err := loadCommitContext()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Config.ResolveTicket(ctx.Match, ctx.Branch, opts); err != nil {
+		return nil, fmt.Errorf("failed to resolve ticket of branch %s: %w", ctx.Branch, err)
+	}
+	log.Infof("found ticket: %s from %s", ctx.Match.Ticket, ctx.Match.TicketSource)
+	return ctx, nil
+}
+
+// LoadLintContext is LoadCommitContext for checking existing messages,
+// a branch without ticket is not an error and the user is never asked
+func LoadLintContext() (*CommitContext, error) {
+	ctx, err := loadCommitContext()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Config.ResolveTicket(ctx.Match, ctx.Branch, TicketOptions{}); err != nil && !errors.Is(err, ErrTicketNotFound) {
+		return nil, fmt.Errorf("failed to resolve ticket of branch %s: %w", ctx.Branch, err)
+	}
+	return ctx, nil
+}
+
+// loadCommitContext resolves the repository, branch, config and branch match of the current directory
+func loadCommitContext() (*CommitContext, error) {
+	// Check if current directory is a git repo and get its remote identity
+	repo, err := GetRepoIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository name: %w", err)
+	}
+	log.Infof("repository: %s (remote %s)", repo, repo.Remote)
+
+	branch, err := GetCurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	log.Infof("branch: %s", branch)
+
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	match, err := config.MatchBranch(repo, branch)
+	if err != nil && !errors.Is(err, ErrTicketNotFound) {
+		return nil, fmt.Errorf("failed to match branch: %w", err)
+	}
+	if match.Repo != nil {
+		log.Infof("using repository config: %s (%s match)", match.Repo.Name, match.RepoMatch)
+	}
+
+	return &CommitContext{
+		Config: config,
+		Repo:   repo,
+		Branch: branch,
+		Match:  match,
+	}, nil
+}
+
+// Render renders the commit message template of the current branch for message
+func (ctx *CommitContext) Render(message string) (string, error) {
+	data := NewCommitData(ctx.Match, ctx.Repo, ctx.Branch, message)
+	data.Type, data.Scope, data.Breaking = ctx.Header.Type, ctx.Header.Scope, ctx.Header.Breaking
+	return ctx.Config.RenderCommitMessage(ctx.Match.Settings.Commit.Message, data)
+}
+
+// messagePlaceholder stands in for the message when reverse-matching the template,
+// control characters are left alone by the template functions
+const messagePlaceholder = "\x00\x01message\x01\x00"
+
+// Satisfies reports whether message already has the shape of the rendered
+// template, i.e. it starts with the text rendered before {{.Message}} and
+// contains the text rendered after it
+func (ctx *CommitContext) Satisfies(message string) (bool, error) {
+	rendered, err := ctx.Render(messagePlaceholder)
+	if err != nil {
+		return false, err
+	}
+
+	idx := strings.Index(rendered, messagePlaceholder)
+	if idx < 0 {
+		// The template transforms the message, fall back to looking for the tickets
+		for _, ticket := range ctx.Match.Tickets {
+			if !strings.Contains(message, ticket) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	prefix := strings.TrimSpace(rendered[:idx])
+	suffix := strings.TrimSpace(rendered[idx+len(messagePlaceholder):])
+
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, prefix) {
+		return false, nil
+	}
+	return strings.Contains(message[len(prefix):], suffix), nil
+}
+
+// Apply renders the template for the subject of message and keeps its body.
+// An empty message or a subject that already matches the template is returned unchanged.
+func (ctx *CommitContext) Apply(message *CommitMessage) error {
+	subject := message.Subject()
+	if strings.TrimSpace(subject) == "" {
+		return nil
+	}
+	satisfied, err := ctx.Satisfies(subject)
+	if err != nil || satisfied {
+		return err
+	}
+	rendered, err := ctx.Render(subject)
+	if err != nil {
+		return err
+	}
+	message.SetSubject(rendered)
+	return nil
+}
+
+// TakeConventionalHeader sets the Conventional Commits header from the subject of
+// message when no type was chosen yet. The header is removed from the subject
+// unless the subject already matches the template, so it is not rendered twice.
+func (ctx *CommitContext) TakeConventionalHeader(message *CommitMessage) error {
+	conventional := ctx.Match.Settings.Conventional
+	if !conventional.IsEnabled() {
+		return nil
+	}
+	subject := message.Subject()
+	header, rest, ok := conventional.ParseHeader(subject)
+	if !ok {
+		return nil
+	}
+	if ctx.Header.Type == "" {
+		ctx.Header = header
+	}
+	satisfied, err := ctx.Satisfies(subject)
+	if err != nil || satisfied {
+		return err
+	}
+	message.SetSubject(rest)
+	return nil
+}
+
+// CheckConventional returns an error if Conventional Commits are enabled and
+// the commit has no valid type and scope
+func (ctx *CommitContext) CheckConventional() error {
+	conventional := ctx.Match.Settings.Conventional
+	if !conventional.IsEnabled() {
+		return nil
+	}
+	if ctx.Header.Type == "" {
+		return fmt.Errorf("conventional commit type required, use --type or start the message with one of: %s",
+			strings.Join(conventional.AllowedTypes(), ", "))
+	}
+	return conventional.Check(ctx.Header)
+}
+
+// scissorsLine marks the end of the message, git drops everything below it
+const scissorsLine = " ------------------------ >8 ------------------------"
+
+// CommitMessage is a commit message split into lines
+type CommitMessage struct {
+	// Lines are all lines of the message, including comments
+	Lines []string
+	// commentChar starts the lines git strips from an edited message
+	commentChar string
+	// stripComments is true when git removes comment lines from the message
+	stripComments bool
+}
+
+// ParseCommitMessage splits text into lines, stripComments tells whether
+// git will remove the comment lines, which is the case for edited messages
+func ParseCommitMessage(text string, stripComments bool) *CommitMessage {
+	commentChar, err := GetGitConfig("core.commentChar")
+	if err != nil || len(commentChar) != 1 {
+		commentChar = "#"
+	}
+	return &CommitMessage{
+		Lines:         strings.Split(text, "\n"),
+		commentChar:   commentChar,
+		stripComments: stripComments,
+	}
+}
+
+// autosquashPrefixes start the subjects git rebase --autosquash looks for
+var autosquashPrefixes = []string{"fixup! ", "squash! ", "amend! "}
+
+// IsAutosquashSubject reports whether subject marks a commit for git rebase
+// --autosquash, which needs the marker at the start of the subject
+func IsAutosquashSubject(subject string) bool {
+	for _, prefix := range autosquashPrefixes {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRevertSubject reports whether subject is the one git revert writes
+func IsRevertSubject(subject string) bool {
+	return strings.HasPrefix(subject, `Revert "`)
+}
+
+// subjectIndex returns the index of the subject line, -1 if the message is empty
+func (m *CommitMessage) subjectIndex() int {
+	for i, line := range m.Lines {
+		if m.stripComments && strings.HasPrefix(line, m.commentChar) {
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// Subject returns the first non-empty line, comment lines are skipped when git strips them
+func (m *CommitMessage) Subject() string {
+	if i := m.subjectIndex(); i >= 0 {
+		return m.Lines[i]
+	}
+	return ""
+}
+
+// SetSubject replaces the subject line, or inserts it at the top of an empty message
+func (m *CommitMessage) SetSubject(subject string) {
+	if i := m.subjectIndex(); i >= 0 {
+		m.Lines[i] = subject
+		return
+	}
+	m.Lines = append([]string{subject}, m.Lines...)
+}
+
+// StripComments removes comment lines and everything below the scissors line
+func (m *CommitMessage) StripComments() {
+	var lines []string
+	for _, line := range m.Lines {
+		if line == m.commentChar+scissorsLine {
+			break
+		}
+		if !strings.HasPrefix(line, m.commentChar) {
+			lines = append(lines, line)
+		}
+	}
+	m.Lines = lines
+	m.stripComments = false
+}
+
+// editHelp is appended to messages opened in the editor
+const editHelp = `
+%[1]s Please enter the commit message for your changes. Lines starting
+%[1]s with '%[1]s' will be ignored, and an empty message aborts the commit.
+`
+
+// Edit opens the message in the editor and reads it back. The file is kept
+// in the git directory so the message survives a failed commit.
+func (m *CommitMessage) Edit(path string) error {
+	text := strings.TrimRight(m.String(), "\n") + "\n" + fmt.Sprintf(editHelp, m.commentChar)
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return err
+	}
+	if err := RunEditor(path); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m.Lines = strings.Split(string(data), "\n")
+	m.stripComments = true
+	return nil
+}
+
+// IsEmpty reports whether the message has no content
+func (m *CommitMessage) IsEmpty() bool {
+	return m.subjectIndex() < 0
+}
+
+// String joins the lines of the message
+func (m *CommitMessage) String() string {
+	return strings.Join(m.Lines, "\n")
+}
+
+// RenderCommitMessage renders the commit message template with data
+func (c *Config) RenderCommitMessage(messageTemplate string, data *CommitData) (string, error) {
+	tmpl, err := c.CommitTemplate(messageTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// GetGitConfig returns the value of a git config key
+func GetGitConfig(key string) (string, error) {
+	output, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetStagedFiles returns the paths of the files staged for commit
+func GetStagedFiles() ([]string, error) {
+	output, err := exec.Command("git", "diff", "--cached", "--name-only", "-z").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(string(output), func(r rune) bool { return r == 0 }), nil
+}
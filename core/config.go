@@ -4,13 +4,17 @@ package core
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"text/template"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zhaojunlucky/golib/pkg/cfg"
-	"gopkg.in/yaml.v3"
 )
 
 var configFile = "config.yaml"
@@ -18,133 +22,410 @@ var config *Config
 
 // Config represents the zgit configuration structure
 type Config struct {
-	Global GlobalConfig `yaml:"global"`
-	Repos  []RepoConfig `yaml:"repos"`
+	Global GlobalConfig `yaml:"global" json:"global"`
+	Repos  []RepoConfig `yaml:"repos,omitempty" json:"repos,omitempty"`
+
+	// layers are the files merged into this config, lowest precedence first
+	layers []*ConfigLayer
+	// patterns and templates are compiled once when the config is loaded
+	patterns  map[string]*regexp.Regexp
+	templates map[string]*template.Template
 }
 
 // GlobalConfig represents global configuration settings
 type GlobalConfig struct {
-	Branches []string     `yaml:"branches"`
-	Commit   CommitConfig `yaml:"commit"`
+	Branches []string     `yaml:"branches,omitempty" json:"branches,omitempty"`
+	Commit   CommitConfig `yaml:"commit,omitempty" json:"commit,omitempty"`
+	Ticket   TicketConfig `yaml:"ticket,omitempty" json:"ticket,omitempty"`
+	// Conventional enforces Conventional Commits
+	Conventional ConventionalConfig `yaml:"conventional,omitempty" json:"conventional,omitempty"`
+	// Lint configures the rules of zgit lint
+	Lint LintConfig `yaml:"lint,omitempty" json:"lint,omitempty"`
+	// CoAuthors map aliases to the people credited with Co-authored-by
+	CoAuthors map[string]CoAuthor `yaml:"coauthors,omitempty" json:"coauthors,omitempty"`
+	// Start configures the branches created by zgit start
+	Start StartConfig `yaml:"start,omitempty" json:"start,omitempty"`
+	// Forges set the forge of hosts zgit open and zgit pr cannot detect
+	Forges []ForgeConfig `yaml:"forges,omitempty" json:"forges,omitempty"`
 }
 
 // CommitConfig represents commit message configuration
 type CommitConfig struct {
-	Message string `yaml:"message"`
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// Joiner joins multiple tickets into {{.Ticket}}, ", " by default
+	Joiner string `yaml:"joiner,omitempty" json:"joiner,omitempty"`
+	// Trailers are appended to every message, e.g. Refs: {{.Ticket}}
+	Trailers []TrailerConfig `yaml:"trailers,omitempty" json:"trailers,omitempty"`
 }
 
-// RepoConfig represents repository-specific configuration
+// defaultTicketJoiner joins multiple tickets when commit.joiner is not set
+const defaultTicketJoiner = ", "
+
+// RepoConfig represents repository-specific configuration.
+// Any global section can be repeated here to override it for the repository:
+// branch patterns are tried before the global ones, and non-empty commit
+// settings replace the global values.
 type RepoConfig struct {
-	Name     string   `yaml:"name"`
-	Branches []string `yaml:"branches"`
+	Name string `yaml:"name" json:"name"`
+	// Host restricts the entry to remotes on a host, e.g. github.com; globs are allowed
+	Host         string `yaml:"host,omitempty" json:"host,omitempty"`
+	GlobalConfig `yaml:",inline"`
+
+	// source is the config file the entry was read from
+	source string
 }
 
-func (c *Config) MatchBranch(repoName, branch string) (string, error) {
-	for _, repo := range c.Repos {
-		if repo.Name != repoName {
+// Source returns the config file the repository entry was read from
+func (r *RepoConfig) Source() string {
+	return r.source
+}
+
+// BranchMatch is the result of matching a branch against the config
+type BranchMatch struct {
+	// Ticket is Tickets joined with commit.joiner
+	Ticket string
+	// Tickets are all tickets of the branch in order, without duplicates
+	Tickets []string
+	// Groups are all named capture groups of Pattern, e.g. ticket, type, scope
+	Groups  map[string]string
+	Pattern string
+	// PatternSource is the config file that defined Pattern
+	PatternSource string
+	// Repo is the repository entry that applied, nil if only global settings were used
+	Repo *RepoConfig
+	// RepoMatch tells how the name of Repo matched the repository
+	RepoMatch RepoMatchKind
+	// Settings are the effective settings after merging Repo over Global
+	Settings GlobalConfig
+	// TicketSource tells where the ticket came from, see TicketFromBranch
+	TicketSource string
+}
+
+// ErrTicketNotFound is returned with the effective settings when no branch pattern yields a ticket
+var ErrTicketNotFound = errors.New("no ticket found in the branch name or the ticket fallbacks")
+
+// merge returns a copy of g with the sections of override applied on top
+func (g GlobalConfig) merge(override GlobalConfig) GlobalConfig {
+	merged := g
+	merged.Branches = append(append([]string{}, override.Branches...), g.Branches...)
+	merged.Commit = g.Commit.merge(override.Commit)
+	merged.Ticket = g.Ticket.merge(override.Ticket)
+	merged.Conventional = g.Conventional.merge(override.Conventional)
+	merged.Lint = g.Lint.merge(override.Lint)
+	merged.Start = g.Start.merge(override.Start)
+	merged.Forges = append(append([]ForgeConfig{}, override.Forges...), g.Forges...)
+	if len(override.CoAuthors) > 0 {
+		merged.CoAuthors = maps.Clone(g.CoAuthors)
+		if merged.CoAuthors == nil {
+			merged.CoAuthors = map[string]CoAuthor{}
+		}
+		maps.Copy(merged.CoAuthors, override.CoAuthors)
+	}
+	return merged
+}
+
+// merge returns a copy of c with the non-empty fields of override applied on top
+func (c CommitConfig) merge(override CommitConfig) CommitConfig {
+	merged := c
+	if override.Message != "" {
+		merged.Message = override.Message
+	}
+	if override.Joiner != "" {
+		merged.Joiner = override.Joiner
+	}
+	if len(override.Trailers) > 0 {
+		merged.Trailers = override.Trailers
+	}
+	return merged
+}
+
+// findRepo returns the most specific repository entry for repo, or nil if there is none.
+// Exact names win over globs, globs win over regexes, entries with a host win
+// over entries without one, and the first entry wins a tie.
+func (c *Config) findRepo(repo *RepoIdentity) (*RepoConfig, RepoMatchKind) {
+	var best *RepoConfig
+	bestKind, bestHost, bestScore := RepoMatchNone, false, 0
+	for i := range c.Repos {
+		entry := &c.Repos[i]
+		if entry.Host != "" {
+			if matched, err := path.Match(strings.ToLower(entry.Host), repo.Host); err != nil || !matched {
+				continue
+			}
+		}
+		kind, score := c.matchRepoName(entry.Name, repo.FullName())
+		if kind == RepoMatchNone {
 			continue
 		}
-		for _, branchPattern := range repo.Branches {
-			ticket, err := c.matchBranch(repo.Name, branchPattern, branch)
+		hasHost := entry.Host != ""
+		if kind > bestKind ||
+			(kind == bestKind && hasHost && !bestHost) ||
+			(kind == bestKind && hasHost == bestHost && score > bestScore) {
+			best, bestKind, bestHost, bestScore = entry, kind, hasHost, score
+		}
+	}
+	return best, bestKind
+}
+
+// Settings returns the effective settings for repo, the global settings
+// merged with the most specific repository entry
+func (c *Config) Settings(repo *RepoIdentity) GlobalConfig {
+	if entry, _ := c.findRepo(repo); entry != nil {
+		return c.Global.merge(entry.GlobalConfig)
+	}
+	return c.Global
+}
+
+// MatchBranch extracts the ticket from branch using the patterns of the
+// repository entry matching repo followed by the global patterns, and returns
+// it with the effective settings
+func (c *Config) MatchBranch(repo *RepoIdentity, branch string) (*BranchMatch, error) {
+	result := &BranchMatch{
+		Settings: c.Global,
+	}
+	result.Repo, result.RepoMatch = c.findRepo(repo)
+	result.TicketSource = TicketFromBranch
+	if result.Repo != nil {
+		result.Settings = c.Global.merge(result.Repo.GlobalConfig)
+		for _, branchPattern := range result.Repo.Branches {
+			groups, tickets, err := c.matchBranch(result.Repo.Name, branchPattern, branch)
 			if err != nil {
-				return "", err
-			} else if len(ticket) > 0 {
-				return ticket, nil
+				return nil, err
+			} else if len(tickets) > 0 {
+				result.Groups = groups
+				result.setTickets(tickets, TicketFromBranch)
+				result.Pattern = branchPattern
+				result.PatternSource = result.Repo.source
+				return result, nil
 			}
 		}
 	}
 
 	for _, branchPattern := range c.Global.Branches {
-		ticket, err := c.matchBranch("global", branchPattern, branch)
+		groups, tickets, err := c.matchBranch("global", branchPattern, branch)
 		if err != nil {
-			return "", err
-		} else if len(ticket) > 0 {
-			return ticket, nil
+			return nil, err
+		} else if len(tickets) > 0 {
+			result.Groups = groups
+			result.setTickets(tickets, TicketFromBranch)
+			result.Pattern = branchPattern
+			result.PatternSource = c.globalPatternSource(branchPattern)
+			return result, nil
 		}
 	}
-	return "", errors.New("branch not found or ticket group not matched")
+	return result, ErrTicketNotFound
 }
 
-func (c *Config) matchBranch(repoName, branchPattern, branch string) (string, error) {
-	if !strings.Contains(branchPattern, "?P<ticket>") {
-		log.Errorf("branch pattern %s of %s must contain ?P<ticket>", branchPattern, repoName)
-		return "", errors.New("branch pattern must contain ?P<ticket>")
+// matchBranch returns the named groups of the first match of branchPattern
+// against branch and the tickets of every match. Tickets are in "ticket"
+// groups, which may be repeated in the pattern, e.g.
+// (?P<ticket>JIRA-\d+)(?:-(?P<ticket>JIRA-\d+))?. It returns nil if the
+// branch does not match.
+func (c *Config) matchBranch(repoName, branchPattern, branch string) (map[string]string, []string, error) {
+	reg, err := c.regexp(branchPattern)
+	if err != nil {
+		log.Errorf("invalid branch pattern %s of %s: %v", branchPattern, repoName, err)
+		return nil, nil, err
 	}
-	reg := regexp.MustCompile(branchPattern)
-	match := reg.FindStringSubmatch(branch)
+	matches := reg.FindAllStringSubmatch(branch, -1)
 
-	if match == nil {
-		return "", nil
+	if matches == nil {
+		return nil, nil, nil
 	}
 
-	// Check if regex has a named group "ticket"
-	groupNames := reg.SubexpNames()
-	for i, name := range groupNames {
-		if name == "ticket" && i < len(match) {
-			return match[i], nil
+	groups := map[string]string{}
+	var tickets []string
+	for n, match := range matches {
+		for i, name := range reg.SubexpNames() {
+			if name == "" || i >= len(match) {
+				continue
+			}
+			if name == "ticket" {
+				tickets = appendTicket(tickets, match[i])
+			}
+			// A repeated group keeps its first non-empty value
+			if n == 0 && groups[name] == "" {
+				groups[name] = match[i]
+			}
 		}
 	}
-	return "", nil
+	return groups, tickets, nil
 }
 
-// LoadConfig loads the zgit configuration from the specified file path
+// LoadConfig loads the user config and layers the repository-local
+// .zgit.yaml over it when the current directory is inside a repository
 func LoadConfig() (*Config, error) {
 	if config != nil {
 		return config, nil
 	}
 
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := validateConfigLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers {
+		log.Infof("used %s config file: %s", layer.Name, layer.Path)
+	}
+	config = merged
+	return config, nil
+}
+
+// loadConfigLayers reads the user config and the repository-local config, lowest precedence first
+func loadConfigLayers() ([]*ConfigLayer, error) {
+	var layers []*ConfigLayer
 	cfgFilePaths := cfg.GetCfgPath("zgit", configFile)
 	for _, cfgFilePath := range cfgFilePaths {
 		log.Infof("try loading %s", cfgFilePath)
-		data, err := os.ReadFile(cfgFilePath)
-		if err != nil {
+		layer, err := loadConfigLayer(UserLayer, cfgFilePath)
+		if errors.Is(err, fs.ErrNotExist) {
 			log.Debugf("failed to read %s: %v", cfgFilePath, err)
 			continue
+		} else if err != nil {
+			return nil, err
 		}
+		layers = append(layers, layer)
+		break
+	}
 
-		config = &Config{}
-		if err := yaml.Unmarshal(data, config); err != nil {
+	if repoCfgFilePath, err := GetRepoConfigPath(); err == nil {
+		layer, err := loadConfigLayer(RepositoryLayer, repoCfgFilePath)
+		if err == nil {
+			layers = append(layers, layer)
+		} else if !errors.Is(err, fs.ErrNotExist) {
 			return nil, err
 		}
+	}
 
-		err = config.validate()
-		if err != nil {
-			return nil, err
+	if len(layers) == 0 {
+		return nil, errors.New("config file not found")
+	}
+	return layers, nil
+}
+
+// validateConfigLayers merges the layers into a single config, lowest
+// precedence first, and compiles it. Every problem of every layer is
+// collected into ValidationErrors.
+func validateConfigLayers(layers []*ConfigLayer) (*Config, error) {
+	var errs ValidationErrors
+	merged := &Config{}
+	for _, layer := range layers {
+		errs = append(errs, layer.validate()...)
+		merged.apply(layer)
+	}
+	errs = append(errs, merged.validate()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	merged.compile()
+	return merged, nil
+}
+
+// compile caches the compiled branch patterns, repository regexes and commit
+// templates of a validated config
+func (c *Config) compile() {
+	c.patterns = map[string]*regexp.Regexp{}
+	c.templates = map[string]*template.Template{}
+	sections := []GlobalConfig{c.Global}
+	for _, repo := range c.Repos {
+		sections = append(sections, repo.GlobalConfig)
+		if strings.HasPrefix(repo.Name, regexRepoPrefix) {
+			_, _ = c.regexp(repoNameRegex(repo.Name))
 		}
-		log.Infof("used config file: %s", cfgFilePath)
-		return config, nil
 	}
-	return nil, errors.New("config file not found")
+	for _, section := range sections {
+		for _, branchPattern := range section.Branches {
+			_, _ = c.regexp(branchPattern)
+		}
+		if section.Commit.Message != "" {
+			_, _ = c.CommitTemplate(section.Commit.Message)
+		}
+	}
+}
+
+// regexp returns the compiled pattern, compiling and caching it on first use
+func (c *Config) regexp(pattern string) (*regexp.Regexp, error) {
+	if reg, ok := c.patterns[pattern]; ok {
+		return reg, nil
+	}
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if c.patterns == nil {
+		c.patterns = map[string]*regexp.Regexp{}
+	}
+	c.patterns[pattern] = reg
+	return reg, nil
 }
 
-// validate checks if the config is valid
-func (c *Config) validate() error {
-	// Check if commit message template contains {{.Ticket}} and {{.Message}}
-	// Allow optional spaces: {{ .Ticket }} or {{.Ticket}}
-	messageTemplate := c.Global.Commit.Message
-	ticketRegex := regexp.MustCompile(`\{\{\s*\.Ticket\s*\}\}`)
-	messageRegex := regexp.MustCompile(`\{\{\s*\.Message\s*\}\}`)
+// CommitTemplate returns the parsed commit message template, parsing and caching it on first use
+func (c *Config) CommitTemplate(message string) (*template.Template, error) {
+	if tmpl, ok := c.templates[message]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := newCommitTemplate(message)
+	if err != nil {
+		return nil, err
+	}
+	if c.templates == nil {
+		c.templates = map[string]*template.Template{}
+	}
+	c.templates[message] = tmpl
+	return tmpl, nil
+}
 
-	if !ticketRegex.MatchString(messageTemplate) {
-		return errors.New("commit message template must contain {{.Ticket}} or {{ .Ticket }}")
+// GetUserConfigPath returns the user config file in effect, or the default
+// location under ~/.config/zgit when there is none yet
+func GetUserConfigPath() (string, error) {
+	cfgFilePaths := cfg.GetCfgPath("zgit", configFile)
+	for _, cfgFilePath := range cfgFilePaths {
+		if _, err := os.Stat(cfgFilePath); err == nil {
+			return cfgFilePath, nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
 	}
-	if !messageRegex.MatchString(messageTemplate) {
-		return errors.New("commit message template must contain {{.Message}} or {{ .Message }}")
+	return filepath.Join(homeDir, ".config", "zgit", configFile), nil
+}
+
+// GetRepoConfigPath returns the path of the repository-local config file
+func GetRepoConfigPath() (string, error) {
+	repoRoot, err := GetRepoRoot()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(repoRoot, repoConfigFile), nil
+}
 
-	// Check if at least one global branch pattern exists
+// validate checks the merged config, problems of single entries are reported by the layers
+func (c *Config) validate() ValidationErrors {
+	var errs ValidationErrors
 	if len(c.Global.Branches) == 0 {
-		return errors.New("at least one global branch pattern must be defined")
+		errs = append(errs, &ValidationError{Message: "at least one global branch pattern must be defined"})
+	}
+	if c.Global.Commit.Message == "" {
+		errs = append(errs, &ValidationError{Message: "global commit message template must be defined"})
 	}
 
-	// If repos are defined, check that each repo has at least one branch pattern
-	if len(c.Repos) > 0 {
-		for _, repo := range c.Repos {
-			if len(repo.Branches) == 0 {
-				return fmt.Errorf("repository '%s' must have at least one branch pattern", repo.Name)
-			}
+	check := func(file, owner string, settings GlobalConfig) {
+		for _, problem := range settings.problems() {
+			errs = append(errs, &ValidationError{File: file, Message: fmt.Sprintf("%s: %s", owner, problem)})
 		}
 	}
-
-	return nil
+	check("", "global", c.Global)
+	for _, repo := range c.Repos {
+		// A repository inheriting the commit settings is covered by the global check
+		if repo.Commit.Message == "" && len(repo.Commit.Trailers) == 0 && repo.Conventional.Enabled == nil {
+			continue
+		}
+		check(repo.source, fmt.Sprintf("repository '%s'", repo.Name), c.Global.merge(repo.GlobalConfig))
+	}
+	return errs
 }
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigFile is the repository-local config file at the repository root
+var repoConfigFile = ".zgit.yaml"
+
+const (
+	// UserLayer is the config found in the current directory, ~/.config/zgit or /etc/zgit
+	UserLayer = "user"
+	// RepositoryLayer is the .zgit.yaml at the repository root, it takes precedence over UserLayer
+	RepositoryLayer = "repository"
+)
+
+// ConfigLayer is a single config file that contributed to the effective config
+type ConfigLayer struct {
+	Name   string
+	Path   string
+	Config *Config
+
+	root yaml.Node
+	// decodeErrs are the unknown keys and type mismatches found while decoding
+	decodeErrs ValidationErrors
+}
+
+// loadConfigLayer reads and decodes a single config file
+func loadConfigLayer(name, path string) (*ConfigLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &ConfigLayer{
+		Name:   name,
+		Path:   path,
+		Config: &Config{},
+	}
+	if err := yaml.Unmarshal(data, &layer.root); err != nil {
+		return nil, yamlValidationErrors(path, err)
+	}
+	if len(layer.root.Content) > 0 {
+		// Decode again in strict mode, the node keeps the locations for validation
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(layer.Config); err != nil {
+			layer.decodeErrs = yamlValidationErrors(path, err)
+		}
+	}
+	for i := range layer.Config.Repos {
+		layer.Config.Repos[i].source = path
+	}
+	return layer, nil
+}
+
+// yamlLineRegex extracts the line from yaml errors such as "yaml: line 3: did not find expected key"
+var yamlLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// unknownFieldRegex matches the error reported by strict decoding for unknown keys
+var unknownFieldRegex = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+
+// yamlValidationErrors converts a yaml decoding error into located validation errors
+func yamlValidationErrors(path string, err error) ValidationErrors {
+	messages := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	var errs ValidationErrors
+	for _, message := range messages {
+		validationErr := &ValidationError{File: path, Message: message}
+		if matches := yamlLineRegex.FindStringSubmatch(message); matches != nil {
+			validationErr.Line, _ = strconv.Atoi(matches[1])
+			validationErr.Message = matches[2]
+		}
+		if matches := unknownFieldRegex.FindStringSubmatch(validationErr.Message); matches != nil {
+			validationErr.Message = "unknown key " + matches[1]
+		}
+		errs = append(errs, validationErr)
+	}
+	return errs
+}
+
+// lookup returns the YAML node at the dotted key, e.g. "global.commit.message"
+func (l *ConfigLayer) lookup(key string) *yaml.Node {
+	if len(l.root.Content) == 0 {
+		return nil
+	}
+	return lookupNode(l.root.Content[0], key)
+}
+
+// lookupNode returns the YAML node at the dotted key below node
+func lookupNode(node *yaml.Node, key string) *yaml.Node {
+	for _, name := range strings.Split(key, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// apply merges layer over c: branch patterns and repository entries of the
+// layer are tried first, and its non-empty settings replace the current ones
+func (c *Config) apply(layer *ConfigLayer) {
+	c.Global = c.Global.merge(layer.Config.Global)
+	c.Repos = append(append([]RepoConfig{}, layer.Config.Repos...), c.Repos...)
+	c.layers = append(c.layers, layer)
+}
+
+// Layers returns the config files merged into c, lowest precedence first
+func (c *Config) Layers() []*ConfigLayer {
+	return c.layers
+}
+
+// Sources returns the layers that set the dotted key, e.g. "global.commit.message",
+// highest precedence first. For a single value the first layer is the one in effect,
+// for lists such as "global.branches" every returned layer contributes.
+func (c *Config) Sources(key string) []*ConfigLayer {
+	var sources []*ConfigLayer
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if node := c.layers[i].lookup(key); node != nil {
+			sources = append(sources, c.layers[i])
+		}
+	}
+	return sources
+}
+
+// SettingSource is a setting in effect and the config files that set it
+type SettingSource struct {
+	// Key is the dotted key below global or a repository entry, e.g. commit.message
+	Key string
+	// Sources are the files that set the key, highest precedence first
+	Sources []string
+	// Combined is true when every source contributes, like for branches,
+	// otherwise the first source is in effect
+	Combined bool
+}
+
+// combinedSettings are the settings whose values of every file are combined by merge
+var combinedSettings = map[string]bool{"branches": true, "forges": true}
+
+// SettingSources returns the files that set each setting in effect for the
+// repository entry, which is nil when no entry matches, sorted by key
+func (c *Config) SettingSources(entry *RepoConfig) []SettingSource {
+	sources := map[string][]string{}
+	if entry != nil {
+		var node yaml.Node
+		if err := node.Encode(entry.GlobalConfig); err == nil {
+			for _, key := range settingKeys(&node, "") {
+				sources[key] = append(sources[key], fmt.Sprintf("%s repos[%s]", entry.Source(), entry.Name))
+			}
+		}
+	}
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if global := c.layers[i].lookup("global"); global != nil {
+			for _, key := range settingKeys(global, "") {
+				sources[key] = append(sources[key], c.layers[i].Path)
+			}
+		}
+	}
+
+	var settings []SettingSource
+	for key, files := range sources {
+		settings = append(settings, SettingSource{Key: key, Sources: files, Combined: combinedSettings[key]})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// settingKeys returns the dotted keys set in a config section, sections are
+// followed one level down, e.g. commit.message, coauthors.bob or branches
+func settingKeys(node *yaml.Node, prefix string) []string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := prefix+node.Content[i].Value, node.Content[i+1]
+		if value.Kind == yaml.MappingNode && prefix == "" {
+			keys = append(keys, settingKeys(value, key+".")...)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// globalPatternSource returns the highest precedence layer defining the global branch pattern
+func (c *Config) globalPatternSource(branchPattern string) string {
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		for _, pattern := range c.layers[i].Config.Global.Branches {
+			if pattern == branchPattern {
+				return c.layers[i].Path
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a config problem with the location it was found at
+type ValidationError struct {
+	// File is empty for problems of the merged config
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	default:
+		return e.Message
+	}
+}
+
+// ValidationErrors collects every problem found in the config
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ValidateConfig loads every config layer without caching it and reports all problems
+func ValidateConfig() ([]*ConfigLayer, error) {
+	layers, err := loadConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+	_, err = validateConfigLayers(layers)
+	return layers, err
+}
+
+// validate checks every pattern and template of the layer and reports them with their line
+func (l *ConfigLayer) validate() ValidationErrors {
+	errs := append(ValidationErrors{}, l.decodeErrs...)
+	report := func(node *yaml.Node, format string, args ...any) {
+		errs = append(errs, &ValidationError{
+			File:    l.Path,
+			Line:    node.Line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	checkSection := func(section *yaml.Node, owner string) {
+		if branches := lookupNode(section, "branches"); branches != nil {
+			for _, item := range branches.Content {
+				if err := validateBranchPattern(item.Value); err != nil {
+					report(item, "%s: %v", owner, err)
+				}
+			}
+		}
+		if message := lookupNode(section, "commit.message"); message != nil {
+			if err := validateCommitMessage(message.Value); err != nil {
+				report(message, "%s: %v", owner, err)
+			}
+		}
+		if pattern := lookupNode(section, "ticket.pattern"); pattern != nil {
+			if _, err := regexp.Compile(pattern.Value); err != nil {
+				report(pattern, "%s: invalid ticket pattern: %v", owner, err)
+			}
+		}
+		if paths := lookupNode(section, "conventional.paths"); paths != nil {
+			scopes := lookupNode(section, "conventional.scopes")
+			for _, item := range paths.Content {
+				glob, scope := lookupNode(item, "glob"), lookupNode(item, "scope")
+				if glob == nil || glob.Value == "" || scope == nil || scope.Value == "" {
+					report(item, "%s: conventional path needs a glob and a scope", owner)
+				} else if scopes != nil && !slices.ContainsFunc(scopes.Content, func(n *yaml.Node) bool { return n.Value == scope.Value }) {
+					report(scope, "%s: scope %s of path %s is not in conventional.scopes", owner, scope.Value, glob.Value)
+				}
+			}
+		}
+		if trailers := lookupNode(section, "commit.trailers"); trailers != nil {
+			for _, item := range trailers.Content {
+				key, value := lookupNode(item, "key"), lookupNode(item, "value")
+				if key == nil || !trailerKeyRegex.MatchString(key.Value) {
+					report(item, "%s: commit trailer needs a key such as Refs", owner)
+				}
+				if value == nil || value.Value == "" {
+					report(item, "%s: commit trailer needs a value", owner)
+				} else if _, err := newCommitTemplate(value.Value); err != nil {
+					report(value, "%s: invalid commit trailer template: %v", owner, err)
+				}
+			}
+		}
+		if coAuthors := lookupNode(section, "coauthors"); coAuthors != nil {
+			for j := 0; j+1 < len(coAuthors.Content); j += 2 {
+				alias, coAuthor := coAuthors.Content[j], coAuthors.Content[j+1]
+				name, email := lookupNode(coAuthor, "name"), lookupNode(coAuthor, "email")
+				if name == nil || name.Value == "" || email == nil || !strings.Contains(email.Value, "@") {
+					report(coAuthor, "%s: co-author %s needs a name and an email", owner, alias.Value)
+				}
+			}
+		}
+		if rules := lookupNode(section, "lint.rules"); rules != nil {
+			for _, item := range rules.Content {
+				if !slices.Contains(LintRules, item.Value) {
+					report(item, "%s: unknown lint rule %s", owner, item.Value)
+				}
+			}
+		}
+		if branch := lookupNode(section, "start.branch"); branch != nil {
+			if _, err := newCommitTemplate(branch.Value); err != nil {
+				report(branch, "%s: invalid branch name template: %v", owner, err)
+			} else if !templateUses(branch.Value, "Ticket") {
+				report(branch, "%s: branch name template must contain {{.Ticket}}", owner)
+			}
+		}
+		if forges := lookupNode(section, "forges"); forges != nil {
+			for _, item := range forges.Content {
+				host, forgeType := lookupNode(item, "host"), lookupNode(item, "type")
+				if host == nil || host.Value == "" {
+					report(item, "%s: forge needs a host", owner)
+				} else if _, err := path.Match(host.Value, ""); err != nil {
+					report(host, "%s: invalid forge host glob: %v", owner, err)
+				}
+				if forgeType == nil || !slices.Contains(ForgeTypes, forgeType.Value) {
+					report(item, "%s: forge type must be one of %s", owner, strings.Join(ForgeTypes, ", "))
+				}
+			}
+		}
+		if fallback := lookupNode(section, "ticket.fallback"); fallback != nil {
+			for _, item := range fallback.Content {
+				if !isTicketFallback(item.Value) {
+					report(item, "%s: unknown ticket fallback %s", owner, item.Value)
+				}
+			}
+		}
+	}
+
+	if len(l.root.Content) == 0 {
+		return nil
+	}
+	root := l.root.Content[0]
+	if global := lookupNode(root, "global"); global != nil {
+		checkSection(global, "global")
+	}
+	if repos := lookupNode(root, "repos"); repos != nil {
+		for i, repo := range repos.Content {
+			owner := fmt.Sprintf("repos[%d]", i)
+			name := lookupNode(repo, "name")
+			if name == nil || name.Value == "" {
+				report(repo, "%s: repository name must not be empty", owner)
+			} else {
+				owner = fmt.Sprintf("repository '%s'", name.Value)
+				if err := validateRepoName(name.Value); err != nil {
+					report(name, "%s: %v", owner, err)
+				}
+			}
+			if host := lookupNode(repo, "host"); host != nil {
+				if _, err := path.Match(host.Value, ""); err != nil {
+					report(host, "%s: invalid host glob: %v", owner, err)
+				}
+			}
+			sections := 0
+			for j := 0; j+1 < len(repo.Content); j += 2 {
+				if key := repo.Content[j].Value; key != "name" && key != "host" {
+					sections++
+				}
+			}
+			if sections == 0 {
+				report(repo, "%s must override at least one global section", owner)
+			}
+			checkSection(repo, owner)
+		}
+	}
+	return errs
+}
+
+// trailerKeyRegex matches a git trailer key such as Refs or Signed-off-by
+var trailerKeyRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// validateBranchPattern checks that the pattern compiles and has a ticket group
+func validateBranchPattern(branchPattern string) error {
+	reg, err := regexp.Compile(branchPattern)
+	if err != nil {
+		return fmt.Errorf("invalid branch pattern %s: %w", branchPattern, err)
+	}
+	for _, name := range reg.SubexpNames() {
+		if name == "ticket" {
+			return nil
+		}
+	}
+	return fmt.Errorf("branch pattern %s must contain ?P<ticket>", branchPattern)
+}
+
+// validateCommitMessage checks that the commit message template parses and contains {{.Message}},
+// the ticket may also be added by a trailer which is checked on the merged settings
+func validateCommitMessage(messageTemplate string) error {
+	if _, err := newCommitTemplate(messageTemplate); err != nil {
+		return fmt.Errorf("invalid commit message template: %w", err)
+	}
+	if !templateUses(messageTemplate, "Message") {
+		return errors.New("commit message template must contain {{.Message}} or {{ .Message }}")
+	}
+	return nil
+}
+
+// templateUses reports whether a template uses the field matched by fieldPattern,
+// allowing spaces and functions: {{ .Ticket }}, {{.Ticket}}, {{upper .Ticket}} or {{range .Tickets}}
+func templateUses(messageTemplate, fieldPattern string) bool {
+	return regexp.MustCompile(`\{\{[^}]*\.` + fieldPattern + `\b[^}]*\}\}`).MatchString(messageTemplate)
+}
+
+// problems returns the problems of merged settings that no single config file shows
+func (g GlobalConfig) problems() []string {
+	var problems []string
+	if g.Commit.Message == "" {
+		return nil
+	}
+	hasTicket := templateUses(g.Commit.Message, "Tickets?")
+	for _, trailer := range g.Commit.Trailers {
+		hasTicket = hasTicket || templateUses(trailer.Value, "Tickets?")
+	}
+	if !hasTicket {
+		problems = append(problems, "commit message template or a commit trailer must contain {{.Ticket}} or {{.Tickets}}")
+	}
+	// The type chosen for a conventional commit must reach the message
+	if g.Conventional.IsEnabled() && !templateUses(g.Commit.Message, "Type") {
+		problems = append(problems, "commit message template must contain {{.Type}} when conventional commits are enabled")
+	}
+	return problems
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultConventionalTypes are the allowed types when conventional.types is not configured
+var defaultConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// conventionalHeaderRegex matches the type(scope)!: header of a Conventional Commits subject
+var conventionalHeaderRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]+)\))?(!)?:\s*`)
+
+// ConventionalConfig enables Conventional Commits, the type and scope are
+// passed to the commit message template as {{.Type}} and {{.Scope}}
+type ConventionalConfig struct {
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// Types are the allowed commit types, the Conventional Commits types by default
+	Types []string `yaml:"types,omitempty" json:"types,omitempty"`
+	// Scopes are the allowed scopes, any scope is allowed when empty
+	Scopes []string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	// Paths suggest the scope from the staged files, the first matching glob wins per file
+	Paths []ScopePath `yaml:"paths,omitempty" json:"paths,omitempty"`
+}
+
+// ScopePath maps a directory glob such as "api/**" to a scope
+type ScopePath struct {
+	Glob  string `yaml:"glob" json:"glob"`
+	Scope string `yaml:"scope" json:"scope"`
+}
+
+// ConventionalHeader is the type(scope)! part of a Conventional Commits subject
+type ConventionalHeader struct {
+	Type     string
+	Scope    string
+	Breaking bool
+}
+
+// merge returns a copy of c with the non-empty fields of override applied on top
+func (c ConventionalConfig) merge(override ConventionalConfig) ConventionalConfig {
+	merged := c
+	if override.Enabled != nil {
+		merged.Enabled = override.Enabled
+	}
+	if len(override.Types) > 0 {
+		merged.Types = override.Types
+	}
+	if len(override.Scopes) > 0 {
+		merged.Scopes = override.Scopes
+	}
+	if len(override.Paths) > 0 {
+		merged.Paths = override.Paths
+	}
+	return merged
+}
+
+// IsEnabled reports whether Conventional Commits are enforced
+func (c ConventionalConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// AllowedTypes returns the configured types or the default ones
+func (c ConventionalConfig) AllowedTypes() []string {
+	if len(c.Types) > 0 {
+		return c.Types
+	}
+	return defaultConventionalTypes
+}
+
+// Check validates the type and scope of header against the allowed values
+func (c ConventionalConfig) Check(header ConventionalHeader) error {
+	if !slices.Contains(c.AllowedTypes(), header.Type) {
+		return fmt.Errorf("commit type %q is not one of %s", header.Type, strings.Join(c.AllowedTypes(), ", "))
+	}
+	if header.Scope != "" && len(c.Scopes) > 0 && !slices.Contains(c.Scopes, header.Scope) {
+		return fmt.Errorf("commit scope %q is not one of %s", header.Scope, strings.Join(c.Scopes, ", "))
+	}
+	return nil
+}
+
+// ParseHeader splits a subject into its Conventional Commits header and the
+// rest, it returns false if the subject has no header with an allowed type
+func (c ConventionalConfig) ParseHeader(subject string) (ConventionalHeader, string, bool) {
+	match := conventionalHeaderRegex.FindStringSubmatch(strings.TrimSpace(subject))
+	if match == nil || !slices.Contains(c.AllowedTypes(), match[1]) {
+		return ConventionalHeader{}, subject, false
+	}
+	header := ConventionalHeader{Type: match[1], Scope: match[2], Breaking: match[3] != ""}
+	return header, strings.TrimSpace(subject)[len(match[0]):], true
+}
+
+// SuggestScope returns the scope most staged files map to through the path globs,
+// or "" if no file maps to a scope
+func (c *Config) SuggestScope(conventional ConventionalConfig, files []string) string {
+	counts := map[string]int{}
+	suggested := ""
+	for _, file := range files {
+		for _, scopePath := range conventional.Paths {
+			reg, err := c.regexp(globRegexp(scopePath.Glob))
+			if err != nil || !reg.MatchString(file) {
+				continue
+			}
+			counts[scopePath.Scope]++
+			if suggested == "" || counts[scopePath.Scope] > counts[suggested] {
+				suggested = scopePath.Scope
+			}
+			break
+		}
+	}
+	return suggested
+}
+
+// globRegexp converts a path glob to a regex: ** matches any number of
+// directories, * and ? do not cross a /, and a glob naming a directory
+// also matches the files below it
+func globRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	glob = strings.TrimSuffix(glob, "/")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			sb.WriteString("[^/]*")
+		case glob[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString("(?:/.*)?$")
+	return sb.String()
+}
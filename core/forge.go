@@ -0,0 +1,519 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Forge types
+const (
+	ForgeGitHub          = "github"
+	ForgeGitLab          = "gitlab"
+	ForgeBitbucket       = "bitbucket"
+	ForgeBitbucketServer = "bitbucket-server"
+	ForgeAzureDevOps     = "azure-devops"
+	ForgeGitea           = "gitea"
+)
+
+// ForgeTypes are the supported forge types
+var ForgeTypes = []string{ForgeGitHub, ForgeGitLab, ForgeBitbucket, ForgeBitbucketServer, ForgeAzureDevOps, ForgeGitea}
+
+// commitHashRegex matches abbreviated and full commit hashes
+var commitHashRegex = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// ForgeConfig sets the forge of the repositories on a host, when the host
+// name does not tell it or the web UI is not at https://<host>
+type ForgeConfig struct {
+	// Host is the host of the remote URL, a glob such as *.corp.com is allowed
+	Host string `yaml:"host" json:"host"`
+	// Type is one of ForgeTypes
+	Type string `yaml:"type" json:"type"`
+	// URL is the web UI root, e.g. https://git.corp.com/gitlab, https://<host> by default
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// LineRange selects lines of a file, End is 0 for a single line and Start is 0 for none
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// fileLinesRegex matches a path followed by a line or a line range, e.g. main.go:42-60
+var fileLinesRegex = regexp.MustCompile(`^(.+):([1-9][0-9]*)(?:-([1-9][0-9]*))?$`)
+
+// SplitFileLines splits path:line and path:start-end into the path and the
+// lines, a path without lines is returned as is
+func SplitFileLines(arg string) (string, LineRange, error) {
+	match := fileLinesRegex.FindStringSubmatch(arg)
+	if match == nil {
+		return arg, LineRange{}, nil
+	}
+	lines := LineRange{}
+	lines.Start, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		lines.End, _ = strconv.Atoi(match[3])
+		if lines.End < lines.Start {
+			return "", LineRange{}, fmt.Errorf("line range %d-%d ends before it starts", lines.Start, lines.End)
+		}
+	}
+	return match[1], lines, nil
+}
+
+// Forge builds the web URLs of a repository hosted on a code forge
+type Forge interface {
+	// Type is one of ForgeTypes
+	Type() string
+	RepoURL() string
+	BranchURL(branch string) string
+	CommitURL(commit string) string
+	// FileURL links to path, relative to the repository root, at a branch or commit
+	FileURL(ref, path string, lines LineRange) string
+	// BlameURL links to the blame of path like FileURL, empty if the forge has no blame page
+	BlameURL(ref, path string, lines LineRange) string
+	// IssuesURL is empty if the forge has no issue tracker
+	IssuesURL() string
+	// PipelinesURL links to the CI runs: GitHub Actions, GitLab pipelines...
+	PipelinesURL() string
+	CompareURL(base, head string) string
+	// NewPullRequestURL opens the form for a pull or merge request of head into base
+	NewPullRequestURL(base, head string) string
+}
+
+// Forge returns the forge of repo: the forges config entry of its host, or
+// the forge its host name tells, GitHub otherwise
+func (c *Config) Forge(repo *RepoIdentity) (Forge, error) {
+	return forgeOf(c.Settings(repo).Forges, repo)
+}
+
+// DetectForge returns the forge the host of repo tells, for use without a config
+func DetectForge(repo *RepoIdentity) (Forge, error) {
+	return forgeOf(nil, repo)
+}
+
+// forgeOf returns the forge of repo, the first of forges matching its host wins
+func forgeOf(forges []ForgeConfig, repo *RepoIdentity) (Forge, error) {
+	if repo.Host == "" {
+		return nil, fmt.Errorf("%s is a local repository, it has no web page", repo.FullName())
+	}
+	forgeType, webURL := DetectForgeType(repo.Host), webRoot(repo)
+	for _, forgeConfig := range forges {
+		if ok, _ := path.Match(forgeConfig.Host, repo.Host); ok {
+			forgeType = forgeConfig.Type
+			if forgeConfig.URL != "" {
+				webURL = strings.TrimSuffix(forgeConfig.URL, "/")
+			}
+			break
+		}
+	}
+	return NewForge(forgeType, webURL, repo)
+}
+
+// NewForge returns the forge of forgeType for repo with the web UI at webURL
+func NewForge(forgeType, webURL string, repo *RepoIdentity) (Forge, error) {
+	switch forgeType {
+	case ForgeGitHub:
+		return githubForge{base: webURL + "/" + repo.FullName()}, nil
+	case ForgeGitLab:
+		return gitlabForge{base: webURL + "/" + repo.FullName()}, nil
+	case ForgeBitbucket:
+		return bitbucketForge{base: webURL + "/" + repo.FullName()}, nil
+	case ForgeBitbucketServer:
+		// Clone URLs over https have a /scm prefix: https://host/scm/PROJ/repo.git
+		project := strings.TrimPrefix(repo.Namespace, "scm/")
+		return bitbucketServerForge{base: fmt.Sprintf("%s/projects/%s/repos/%s", webURL, strings.ToUpper(project), repo.Repo)}, nil
+	case ForgeAzureDevOps:
+		return newAzureDevOpsForge(webURL, repo)
+	case ForgeGitea:
+		return giteaForge{base: webURL + "/" + repo.FullName()}, nil
+	}
+	return nil, fmt.Errorf("unknown forge type %s, use one of %s", forgeType, strings.Join(ForgeTypes, ", "))
+}
+
+// DetectForgeType guesses the forge type from the host name, GitHub when the host does not tell
+func DetectForgeType(host string) string {
+	switch {
+	case host == "bitbucket.org":
+		return ForgeBitbucket
+	case strings.Contains(host, "bitbucket"):
+		return ForgeBitbucketServer
+	case host == "dev.azure.com" || host == "ssh.dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com"):
+		return ForgeAzureDevOps
+	case strings.Contains(host, "gitlab"):
+		return ForgeGitLab
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return ForgeGitea
+	}
+	return ForgeGitHub
+}
+
+// webRoot returns the web UI root of the host of repo: the same scheme and
+// port for http remotes, https on the default port otherwise
+func webRoot(repo *RepoIdentity) string {
+	if repo.Protocol == "http" || repo.Protocol == "https" {
+		root := repo.Protocol + "://" + repo.Host
+		if repo.Port != "" {
+			root += ":" + repo.Port
+		}
+		return root
+	}
+	return "https://" + repo.Host
+}
+
+// escapePath escapes every segment of a slash separated path
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// githubForge builds GitHub and GitHub Enterprise URLs
+type githubForge struct {
+	base string
+}
+
+func (f githubForge) Type() string    { return ForgeGitHub }
+func (f githubForge) RepoURL() string { return f.base }
+
+func (f githubForge) BranchURL(branch string) string {
+	return f.base + "/tree/" + escapePath(branch)
+}
+
+func (f githubForge) CommitURL(commit string) string {
+	return f.base + "/commit/" + commit
+}
+
+func (f githubForge) FileURL(ref, path string, lines LineRange) string {
+	return f.fileURL("blob", ref, path, lines)
+}
+
+func (f githubForge) BlameURL(ref, path string, lines LineRange) string {
+	return f.fileURL("blame", ref, path, lines)
+}
+
+func (f githubForge) fileURL(view, ref, path string, lines LineRange) string {
+	fileURL := f.base + "/" + view + "/" + escapePath(ref) + "/" + escapePath(path)
+	if lines.Start > 0 {
+		fileURL += fmt.Sprintf("#L%d", lines.Start)
+		if lines.End > lines.Start {
+			fileURL += fmt.Sprintf("-L%d", lines.End)
+		}
+	}
+	return fileURL
+}
+
+func (f githubForge) IssuesURL() string    { return f.base + "/issues" }
+func (f githubForge) PipelinesURL() string { return f.base + "/actions" }
+
+func (f githubForge) CompareURL(base, head string) string {
+	return f.base + "/compare/" + escapePath(base) + "..." + escapePath(head)
+}
+
+func (f githubForge) NewPullRequestURL(base, head string) string {
+	return f.CompareURL(base, head) + "?expand=1"
+}
+
+// gitlabForge builds GitLab URLs, the namespace can have subgroups
+type gitlabForge struct {
+	base string
+}
+
+func (f gitlabForge) Type() string    { return ForgeGitLab }
+func (f gitlabForge) RepoURL() string { return f.base }
+
+func (f gitlabForge) BranchURL(branch string) string {
+	return f.base + "/-/tree/" + escapePath(branch)
+}
+
+func (f gitlabForge) CommitURL(commit string) string {
+	return f.base + "/-/commit/" + commit
+}
+
+func (f gitlabForge) FileURL(ref, path string, lines LineRange) string {
+	return f.fileURL("blob", ref, path, lines)
+}
+
+func (f gitlabForge) BlameURL(ref, path string, lines LineRange) string {
+	return f.fileURL("blame", ref, path, lines)
+}
+
+func (f gitlabForge) fileURL(view, ref, path string, lines LineRange) string {
+	fileURL := f.base + "/-/" + view + "/" + escapePath(ref) + "/" + escapePath(path)
+	if lines.Start > 0 {
+		fileURL += fmt.Sprintf("#L%d", lines.Start)
+		if lines.End > lines.Start {
+			fileURL += fmt.Sprintf("-%d", lines.End)
+		}
+	}
+	return fileURL
+}
+
+func (f gitlabForge) IssuesURL() string    { return f.base + "/-/issues" }
+func (f gitlabForge) PipelinesURL() string { return f.base + "/-/pipelines" }
+
+func (f gitlabForge) CompareURL(base, head string) string {
+	return f.base + "/-/compare/" + escapePath(base) + "..." + escapePath(head)
+}
+
+func (f gitlabForge) NewPullRequestURL(base, head string) string {
+	query := url.Values{}
+	query.Set("merge_request[source_branch]", head)
+	query.Set("merge_request[target_branch]", base)
+	return f.base + "/-/merge_requests/new?" + query.Encode()
+}
+
+// bitbucketForge builds Bitbucket Cloud URLs
+type bitbucketForge struct {
+	base string
+}
+
+func (f bitbucketForge) Type() string    { return ForgeBitbucket }
+func (f bitbucketForge) RepoURL() string { return f.base }
+
+func (f bitbucketForge) BranchURL(branch string) string {
+	return f.base + "/src/" + escapePath(branch)
+}
+
+func (f bitbucketForge) CommitURL(commit string) string {
+	return f.base + "/commits/" + commit
+}
+
+func (f bitbucketForge) FileURL(ref, path string, lines LineRange) string {
+	return f.fileURL("src", ref, path, lines)
+}
+
+func (f bitbucketForge) BlameURL(ref, path string, lines LineRange) string {
+	return f.fileURL("annotate", ref, path, lines)
+}
+
+func (f bitbucketForge) fileURL(view, ref, path string, lines LineRange) string {
+	fileURL := f.base + "/" + view + "/" + escapePath(ref) + "/" + escapePath(path)
+	if lines.Start > 0 {
+		fileURL += fmt.Sprintf("#lines-%d", lines.Start)
+		if lines.End > lines.Start {
+			fileURL += fmt.Sprintf(":%d", lines.End)
+		}
+	}
+	return fileURL
+}
+
+func (f bitbucketForge) IssuesURL() string    { return f.base + "/issues" }
+func (f bitbucketForge) PipelinesURL() string { return f.base + "/pipelines" }
+
+func (f bitbucketForge) CompareURL(base, head string) string {
+	// The compare page takes the source first, separated by a carriage return
+	return f.base + "/branches/compare/" + escapePath(head) + "%0D" + escapePath(base)
+}
+
+func (f bitbucketForge) NewPullRequestURL(base, head string) string {
+	query := url.Values{}
+	query.Set("source", head)
+	query.Set("dest", base)
+	return f.base + "/pull-requests/new?" + query.Encode()
+}
+
+// bitbucketServerForge builds Bitbucket Server and Data Center URLs
+type bitbucketServerForge struct {
+	base string
+}
+
+func (f bitbucketServerForge) Type() string    { return ForgeBitbucketServer }
+func (f bitbucketServerForge) RepoURL() string { return f.base + "/browse" }
+
+func (f bitbucketServerForge) BranchURL(branch string) string {
+	return f.base + "/browse?at=" + url.QueryEscape("refs/heads/"+branch)
+}
+
+func (f bitbucketServerForge) CommitURL(commit string) string {
+	return f.base + "/commits/" + commit
+}
+
+func (f bitbucketServerForge) FileURL(ref, path string, lines LineRange) string {
+	if !commitHashRegex.MatchString(ref) {
+		ref = "refs/heads/" + ref
+	}
+	fileURL := f.base + "/browse/" + escapePath(path) + "?at=" + url.QueryEscape(ref)
+	if lines.Start > 0 {
+		fileURL += fmt.Sprintf("#%d", lines.Start)
+		if lines.End > lines.Start {
+			fileURL += fmt.Sprintf("-%d", lines.End)
+		}
+	}
+	return fileURL
+}
+
+// BlameURL is empty, blame is a toggle of the file page without a URL of its own
+func (f bitbucketServerForge) BlameURL(ref, path string, lines LineRange) string { return "" }
+
+// IssuesURL is empty, Bitbucket Server leaves issues to Jira
+func (f bitbucketServerForge) IssuesURL() string    { return "" }
+func (f bitbucketServerForge) PipelinesURL() string { return f.base + "/builds" }
+
+func (f bitbucketServerForge) CompareURL(base, head string) string {
+	query := url.Values{}
+	query.Set("sourceBranch", "refs/heads/"+head)
+	query.Set("targetBranch", "refs/heads/"+base)
+	return f.base + "/compare/commits?" + query.Encode()
+}
+
+func (f bitbucketServerForge) NewPullRequestURL(base, head string) string {
+	query := url.Values{}
+	query.Set("sourceBranch", "refs/heads/"+head)
+	query.Set("targetBranch", "refs/heads/"+base)
+	return f.base + "/pull-requests?create&" + query.Encode()
+}
+
+// azureDevOpsForge builds Azure DevOps and Azure DevOps Server URLs
+type azureDevOpsForge struct {
+	base string
+}
+
+// newAzureDevOpsForge finds the organization, project and repository in the
+// remote URL forms of Azure DevOps:
+//   - https://dev.azure.com/org/project/_git/repo
+//   - git@ssh.dev.azure.com:v3/org/project/repo
+//   - https://org.visualstudio.com/project/_git/repo
+//   - org@vs-ssh.visualstudio.com:v3/org/project/repo
+func newAzureDevOpsForge(webURL string, repo *RepoIdentity) (Forge, error) {
+	namespace := strings.TrimSuffix(strings.TrimPrefix(repo.Namespace, "v3/"), "/_git")
+	if strings.HasSuffix(repo.Host, "visualstudio.com") {
+		// The organization is the subdomain, the SSH host does not have it
+		org, project, found := strings.Cut(namespace, "/")
+		if repo.Host != "vs-ssh.visualstudio.com" {
+			project, found = namespace, namespace != ""
+		} else {
+			webURL = "https://" + org + ".visualstudio.com"
+		}
+		if !found {
+			return nil, fmt.Errorf("no project in the Azure DevOps remote %s", repo)
+		}
+		return azureDevOpsForge{base: fmt.Sprintf("%s/%s/_git/%s", webURL, project, repo.Repo)}, nil
+	}
+	if repo.Host == "ssh.dev.azure.com" && strings.HasPrefix(webURL, "https://ssh.dev.azure.com") {
+		webURL = "https://dev.azure.com"
+	}
+	if strings.Count(namespace, "/") < 1 {
+		return nil, fmt.Errorf("no organization and project in the Azure DevOps remote %s", repo)
+	}
+	return azureDevOpsForge{base: fmt.Sprintf("%s/%s/_git/%s", webURL, namespace, repo.Repo)}, nil
+}
+
+// azureVersion returns the version parameter of a branch or a commit
+func azureVersion(ref string) string {
+	if commitHashRegex.MatchString(ref) {
+		return "GC" + ref
+	}
+	return "GB" + ref
+}
+
+func (f azureDevOpsForge) Type() string    { return ForgeAzureDevOps }
+func (f azureDevOpsForge) RepoURL() string { return f.base }
+
+func (f azureDevOpsForge) BranchURL(branch string) string {
+	return f.base + "?version=" + url.QueryEscape("GB"+branch)
+}
+
+func (f azureDevOpsForge) CommitURL(commit string) string {
+	return f.base + "/commit/" + commit
+}
+
+func (f azureDevOpsForge) FileURL(ref, path string, lines LineRange) string {
+	query := url.Values{}
+	query.Set("path", "/"+path)
+	query.Set("version", azureVersion(ref))
+	if lines.Start > 0 {
+		end := max(lines.End, lines.Start)
+		query.Set("line", fmt.Sprint(lines.Start))
+		query.Set("lineEnd", fmt.Sprint(end+1))
+		query.Set("lineStartColumn", "1")
+		query.Set("lineEndColumn", "1")
+		query.Set("lineStyle", "plain")
+	}
+	return f.base + "?" + query.Encode()
+}
+
+func (f azureDevOpsForge) BlameURL(ref, path string, lines LineRange) string {
+	return f.FileURL(ref, path, lines) + "&_a=blame"
+}
+
+// project returns the URL of the project, work items and pipelines belong to it
+func (f azureDevOpsForge) project() string {
+	project, _, _ := strings.Cut(f.base, "/_git/")
+	return project
+}
+
+func (f azureDevOpsForge) IssuesURL() string    { return f.project() + "/_workitems" }
+func (f azureDevOpsForge) PipelinesURL() string { return f.project() + "/_build" }
+
+func (f azureDevOpsForge) CompareURL(base, head string) string {
+	query := url.Values{}
+	query.Set("baseVersion", "GB"+base)
+	query.Set("targetVersion", "GB"+head)
+	return f.base + "/branchCompare?" + query.Encode()
+}
+
+func (f azureDevOpsForge) NewPullRequestURL(base, head string) string {
+	query := url.Values{}
+	query.Set("sourceRef", head)
+	query.Set("targetRef", base)
+	return f.base + "/pullrequestcreate?" + query.Encode()
+}
+
+// giteaForge builds Gitea and Forgejo URLs
+type giteaForge struct {
+	base string
+}
+
+// giteaRef returns the src path of a branch or a commit
+func giteaRef(ref string) string {
+	if commitHashRegex.MatchString(ref) {
+		return "commit/" + ref
+	}
+	return "branch/" + escapePath(ref)
+}
+
+func (f giteaForge) Type() string    { return ForgeGitea }
+func (f giteaForge) RepoURL() string { return f.base }
+
+func (f giteaForge) BranchURL(branch string) string {
+	return f.base + "/src/branch/" + escapePath(branch)
+}
+
+func (f giteaForge) CommitURL(commit string) string {
+	return f.base + "/commit/" + commit
+}
+
+func (f giteaForge) FileURL(ref, path string, lines LineRange) string {
+	return f.fileURL("src", ref, path, lines)
+}
+
+func (f giteaForge) BlameURL(ref, path string, lines LineRange) string {
+	return f.fileURL("blame", ref, path, lines)
+}
+
+func (f giteaForge) fileURL(view, ref, path string, lines LineRange) string {
+	fileURL := f.base + "/" + view + "/" + giteaRef(ref) + "/" + escapePath(path)
+	if lines.Start > 0 {
+		fileURL += fmt.Sprintf("#L%d", lines.Start)
+		if lines.End > lines.Start {
+			fileURL += fmt.Sprintf("-L%d", lines.End)
+		}
+	}
+	return fileURL
+}
+
+func (f giteaForge) IssuesURL() string    { return f.base + "/issues" }
+func (f giteaForge) PipelinesURL() string { return f.base + "/actions" }
+
+func (f giteaForge) CompareURL(base, head string) string {
+	return f.base + "/compare/" + escapePath(base) + "..." + escapePath(head)
+}
+
+func (f giteaForge) NewPullRequestURL(base, head string) string {
+	return f.CompareURL(base, head)
+}
@@ -0,0 +1,326 @@
+package core
+
+import (
+	"testing"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+func TestForgeURLs(t *testing.T) {
+	lines := LineRange{Start: 3, End: 5}
+	tests := []struct {
+		remote    string
+		forgeType string
+		repo      string
+		branch    string
+		commit    string
+		file      string
+		blame     string
+		compare   string
+		newPR     string
+		issues    string
+		pipelines string
+	}{
+		{
+			remote:    "git@github.com:owner/repo.git",
+			forgeType: ForgeGitHub,
+			repo:      "https://github.com/owner/repo",
+			branch:    "https://github.com/owner/repo/tree/feat/x",
+			commit:    "https://github.com/owner/repo/commit/" + testCommit,
+			file:      "https://github.com/owner/repo/blob/" + testCommit + "/dir/main.go#L3-L5",
+			blame:     "https://github.com/owner/repo/blame/" + testCommit + "/dir/main.go#L3-L5",
+			compare:   "https://github.com/owner/repo/compare/main...feat/x",
+			newPR:     "https://github.com/owner/repo/compare/main...feat/x?expand=1",
+			issues:    "https://github.com/owner/repo/issues",
+			pipelines: "https://github.com/owner/repo/actions",
+		},
+		{
+			remote:    "https://gitlab.com/group/sub/repo.git",
+			forgeType: ForgeGitLab,
+			repo:      "https://gitlab.com/group/sub/repo",
+			branch:    "https://gitlab.com/group/sub/repo/-/tree/feat/x",
+			commit:    "https://gitlab.com/group/sub/repo/-/commit/" + testCommit,
+			file:      "https://gitlab.com/group/sub/repo/-/blob/" + testCommit + "/dir/main.go#L3-5",
+			blame:     "https://gitlab.com/group/sub/repo/-/blame/" + testCommit + "/dir/main.go#L3-5",
+			compare:   "https://gitlab.com/group/sub/repo/-/compare/main...feat/x",
+			newPR:     "https://gitlab.com/group/sub/repo/-/merge_requests/new?merge_request%5Bsource_branch%5D=feat%2Fx&merge_request%5Btarget_branch%5D=main",
+			issues:    "https://gitlab.com/group/sub/repo/-/issues",
+			pipelines: "https://gitlab.com/group/sub/repo/-/pipelines",
+		},
+		{
+			remote:    "git@bitbucket.org:team/repo.git",
+			forgeType: ForgeBitbucket,
+			repo:      "https://bitbucket.org/team/repo",
+			branch:    "https://bitbucket.org/team/repo/src/feat/x",
+			commit:    "https://bitbucket.org/team/repo/commits/" + testCommit,
+			file:      "https://bitbucket.org/team/repo/src/" + testCommit + "/dir/main.go#lines-3:5",
+			blame:     "https://bitbucket.org/team/repo/annotate/" + testCommit + "/dir/main.go#lines-3:5",
+			compare:   "https://bitbucket.org/team/repo/branches/compare/feat/x%0Dmain",
+			newPR:     "https://bitbucket.org/team/repo/pull-requests/new?dest=main&source=feat%2Fx",
+			issues:    "https://bitbucket.org/team/repo/issues",
+			pipelines: "https://bitbucket.org/team/repo/pipelines",
+		},
+		{
+			remote:    "https://bitbucket.corp.com/scm/proj/repo.git",
+			forgeType: ForgeBitbucketServer,
+			repo:      "https://bitbucket.corp.com/projects/PROJ/repos/repo/browse",
+			branch:    "https://bitbucket.corp.com/projects/PROJ/repos/repo/browse?at=refs%2Fheads%2Ffeat%2Fx",
+			commit:    "https://bitbucket.corp.com/projects/PROJ/repos/repo/commits/" + testCommit,
+			file:      "https://bitbucket.corp.com/projects/PROJ/repos/repo/browse/dir/main.go?at=" + testCommit + "#3-5",
+			blame:     "",
+			compare:   "https://bitbucket.corp.com/projects/PROJ/repos/repo/compare/commits?sourceBranch=refs%2Fheads%2Ffeat%2Fx&targetBranch=refs%2Fheads%2Fmain",
+			newPR:     "https://bitbucket.corp.com/projects/PROJ/repos/repo/pull-requests?create&sourceBranch=refs%2Fheads%2Ffeat%2Fx&targetBranch=refs%2Fheads%2Fmain",
+			issues:    "",
+			pipelines: "https://bitbucket.corp.com/projects/PROJ/repos/repo/builds",
+		},
+		{
+			remote:    "https://org@dev.azure.com/org/proj/_git/repo",
+			forgeType: ForgeAzureDevOps,
+			repo:      "https://dev.azure.com/org/proj/_git/repo",
+			branch:    "https://dev.azure.com/org/proj/_git/repo?version=GBfeat%2Fx",
+			commit:    "https://dev.azure.com/org/proj/_git/repo/commit/" + testCommit,
+			file:      "https://dev.azure.com/org/proj/_git/repo?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&lineStyle=plain&path=%2Fdir%2Fmain.go&version=GC" + testCommit,
+			blame:     "https://dev.azure.com/org/proj/_git/repo?line=3&lineEnd=6&lineEndColumn=1&lineStartColumn=1&lineStyle=plain&path=%2Fdir%2Fmain.go&version=GC" + testCommit + "&_a=blame",
+			compare:   "https://dev.azure.com/org/proj/_git/repo/branchCompare?baseVersion=GBmain&targetVersion=GBfeat%2Fx",
+			newPR:     "https://dev.azure.com/org/proj/_git/repo/pullrequestcreate?sourceRef=feat%2Fx&targetRef=main",
+			issues:    "https://dev.azure.com/org/proj/_workitems",
+			pipelines: "https://dev.azure.com/org/proj/_build",
+		},
+		{
+			remote:    "https://codeberg.org/owner/repo.git",
+			forgeType: ForgeGitea,
+			repo:      "https://codeberg.org/owner/repo",
+			branch:    "https://codeberg.org/owner/repo/src/branch/feat/x",
+			commit:    "https://codeberg.org/owner/repo/commit/" + testCommit,
+			file:      "https://codeberg.org/owner/repo/src/commit/" + testCommit + "/dir/main.go#L3-L5",
+			blame:     "https://codeberg.org/owner/repo/blame/commit/" + testCommit + "/dir/main.go#L3-L5",
+			compare:   "https://codeberg.org/owner/repo/compare/main...feat/x",
+			newPR:     "https://codeberg.org/owner/repo/compare/main...feat/x",
+			issues:    "https://codeberg.org/owner/repo/issues",
+			pipelines: "https://codeberg.org/owner/repo/actions",
+		},
+	}
+
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(tt.forgeType, func(t *testing.T) {
+			tested[tt.forgeType] = true
+			repo, err := ParseRemoteURL(tt.remote)
+			if err != nil {
+				t.Fatalf("ParseRemoteURL(%q) error: %v", tt.remote, err)
+			}
+			forge, err := DetectForge(repo)
+			if err != nil {
+				t.Fatalf("DetectForge(%q) error: %v", tt.remote, err)
+			}
+			if forge.Type() != tt.forgeType {
+				t.Fatalf("Type() = %q, want %q", forge.Type(), tt.forgeType)
+			}
+			for _, check := range []struct {
+				name, got, want string
+			}{
+				{"RepoURL", forge.RepoURL(), tt.repo},
+				{"BranchURL", forge.BranchURL("feat/x"), tt.branch},
+				{"CommitURL", forge.CommitURL(testCommit), tt.commit},
+				{"FileURL", forge.FileURL(testCommit, "dir/main.go", lines), tt.file},
+				{"BlameURL", forge.BlameURL(testCommit, "dir/main.go", lines), tt.blame},
+				{"CompareURL", forge.CompareURL("main", "feat/x"), tt.compare},
+				{"NewPullRequestURL", forge.NewPullRequestURL("main", "feat/x"), tt.newPR},
+				{"IssuesURL", forge.IssuesURL(), tt.issues},
+				{"PipelinesURL", forge.PipelinesURL(), tt.pipelines},
+			} {
+				if check.got != check.want {
+					t.Errorf("%s() = %q, want %q", check.name, check.got, check.want)
+				}
+			}
+		})
+	}
+	for _, forgeType := range ForgeTypes {
+		if !tested[forgeType] {
+			t.Errorf("forge type %s is not tested", forgeType)
+		}
+	}
+}
+
+func TestForgeFileLines(t *testing.T) {
+	tests := []struct {
+		forgeType string
+		lines     LineRange
+		want      string
+	}{
+		{ForgeGitHub, LineRange{}, "https://github.com/owner/repo/blob/main/a%20b.go"},
+		{ForgeGitHub, LineRange{Start: 7}, "https://github.com/owner/repo/blob/main/a%20b.go#L7"},
+		{ForgeGitLab, LineRange{Start: 7}, "https://github.com/owner/repo/-/blob/main/a%20b.go#L7"},
+		{ForgeBitbucket, LineRange{Start: 7}, "https://github.com/owner/repo/src/main/a%20b.go#lines-7"},
+		{ForgeBitbucketServer, LineRange{Start: 7}, "https://github.com/projects/OWNER/repos/repo/browse/a%20b.go?at=refs%2Fheads%2Fmain#7"},
+		{ForgeGitea, LineRange{Start: 7}, "https://github.com/owner/repo/src/branch/main/a%20b.go#L7"},
+	}
+	repo := &RepoIdentity{Protocol: "https", Host: "github.com", Namespace: "owner", Repo: "repo"}
+	for _, tt := range tests {
+		forge, err := NewForge(tt.forgeType, "https://github.com", repo)
+		if err != nil {
+			t.Fatalf("NewForge(%q) error: %v", tt.forgeType, err)
+		}
+		if got := forge.FileURL("main", "a b.go", tt.lines); got != tt.want {
+			t.Errorf("%s FileURL(%+v) = %q, want %q", tt.forgeType, tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestForgeRepoURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		remote    string
+		forges    []ForgeConfig
+		forgeType string
+		want      string
+	}{
+		{
+			name:      "azure devops https",
+			remote:    "https://org@dev.azure.com/org/proj/_git/repo",
+			forgeType: ForgeAzureDevOps,
+			want:      "https://dev.azure.com/org/proj/_git/repo",
+		},
+		{
+			name:      "azure devops ssh",
+			remote:    "git@ssh.dev.azure.com:v3/org/proj/repo",
+			forgeType: ForgeAzureDevOps,
+			want:      "https://dev.azure.com/org/proj/_git/repo",
+		},
+		{
+			name:      "azure devops visualstudio.com https",
+			remote:    "https://org.visualstudio.com/proj/_git/repo",
+			forgeType: ForgeAzureDevOps,
+			want:      "https://org.visualstudio.com/proj/_git/repo",
+		},
+		{
+			name:      "azure devops visualstudio.com ssh",
+			remote:    "org@vs-ssh.visualstudio.com:v3/org/proj/repo",
+			forgeType: ForgeAzureDevOps,
+			want:      "https://org.visualstudio.com/proj/_git/repo",
+		},
+		{
+			name:      "bitbucket server https with scm prefix",
+			remote:    "https://bitbucket.corp.com/scm/proj/repo.git",
+			forgeType: ForgeBitbucketServer,
+			want:      "https://bitbucket.corp.com/projects/PROJ/repos/repo/browse",
+		},
+		{
+			name:      "bitbucket server ssh",
+			remote:    "ssh://git@bitbucket.corp.com:7999/proj/repo.git",
+			forgeType: ForgeBitbucketServer,
+			want:      "https://bitbucket.corp.com/projects/PROJ/repos/repo/browse",
+		},
+		{
+			name:      "gitlab subgroups over http keep the port",
+			remote:    "http://gitlab.local:8080/group/sub/deep/repo.git",
+			forgeType: ForgeGitLab,
+			want:      "http://gitlab.local:8080/group/sub/deep/repo",
+		},
+		{
+			name:      "unknown host defaults to github",
+			remote:    "git@git.corp.com:team/repo.git",
+			forgeType: ForgeGitHub,
+			want:      "https://git.corp.com/team/repo",
+		},
+		{
+			name:      "config type and url",
+			remote:    "git@git.corp.com:group/sub/repo.git",
+			forges:    []ForgeConfig{{Host: "git.corp.com", Type: ForgeGitLab, URL: "https://git.corp.com/gitlab/"}},
+			forgeType: ForgeGitLab,
+			want:      "https://git.corp.com/gitlab/group/sub/repo",
+		},
+		{
+			name:      "config host glob",
+			remote:    "git@code.corp.com:owner/repo.git",
+			forges:    []ForgeConfig{{Host: "*.corp.com", Type: ForgeGitea}},
+			forgeType: ForgeGitea,
+			want:      "https://code.corp.com/owner/repo",
+		},
+		{
+			name:   "config first matching entry wins",
+			remote: "https://code.corp.com/proj/repo.git",
+			forges: []ForgeConfig{
+				{Host: "other.corp.com", Type: ForgeGitLab},
+				{Host: "code.corp.com", Type: ForgeBitbucketServer, URL: "https://code.corp.com/bitbucket"},
+				{Host: "*.corp.com", Type: ForgeGitea},
+			},
+			forgeType: ForgeBitbucketServer,
+			want:      "https://code.corp.com/bitbucket/projects/PROJ/repos/repo/browse",
+		},
+		{
+			name:      "config of another host is ignored",
+			remote:    "https://gitlab.com/group/repo.git",
+			forges:    []ForgeConfig{{Host: "git.corp.com", Type: ForgeGitea}},
+			forgeType: ForgeGitLab,
+			want:      "https://gitlab.com/group/repo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := ParseRemoteURL(tt.remote)
+			if err != nil {
+				t.Fatalf("ParseRemoteURL(%q) error: %v", tt.remote, err)
+			}
+			forge, err := forgeOf(tt.forges, repo)
+			if err != nil {
+				t.Fatalf("forgeOf(%q) error: %v", tt.remote, err)
+			}
+			if forge.Type() != tt.forgeType {
+				t.Errorf("Type() = %q, want %q", forge.Type(), tt.forgeType)
+			}
+			if got := forge.RepoURL(); got != tt.want {
+				t.Errorf("RepoURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigForge(t *testing.T) {
+	config := &Config{
+		Global: GlobalConfig{
+			Forges: []ForgeConfig{{Host: "git.corp.com", Type: ForgeGitLab}},
+		},
+		Repos: []RepoConfig{{
+			Name: "team/*",
+			GlobalConfig: GlobalConfig{
+				Forges: []ForgeConfig{{Host: "git.corp.com", Type: ForgeGitea, URL: "https://gitea.corp.com"}},
+			},
+		}},
+	}
+	tests := []struct {
+		remote    string
+		forgeType string
+		want      string
+	}{
+		{"git@git.corp.com:team/repo.git", ForgeGitea, "https://gitea.corp.com/team/repo"},
+		{"git@git.corp.com:other/repo.git", ForgeGitLab, "https://git.corp.com/other/repo"},
+	}
+	for _, tt := range tests {
+		repo, err := ParseRemoteURL(tt.remote)
+		if err != nil {
+			t.Fatalf("ParseRemoteURL(%q) error: %v", tt.remote, err)
+		}
+		forge, err := config.Forge(repo)
+		if err != nil {
+			t.Fatalf("Forge(%q) error: %v", tt.remote, err)
+		}
+		if forge.Type() != tt.forgeType || forge.RepoURL() != tt.want {
+			t.Errorf("Forge(%q) = %s %q, want %s %q", tt.remote, forge.Type(), forge.RepoURL(), tt.forgeType, tt.want)
+		}
+	}
+}
+
+func TestNewForgeErrors(t *testing.T) {
+	if _, err := NewForge("sourcehut", "https://git.sr.ht", &RepoIdentity{Host: "git.sr.ht", Repo: "repo"}); err == nil {
+		t.Error("NewForge with an unknown type succeeded")
+	}
+	repo, err := ParseRemoteURL("https://dev.azure.com/org/repo")
+	if err != nil {
+		t.Fatalf("ParseRemoteURL error: %v", err)
+	}
+	if _, err := DetectForge(repo); err == nil {
+		t.Error("DetectForge of an Azure DevOps remote without project succeeded")
+	}
+}
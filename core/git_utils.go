@@ -4,16 +4,73 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package core
 
 import (
-	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
-// GetCurrentBranch returns the current git branch name
+// GetCurrentBranch returns the current git branch name, or the branch being
+// rebased while a rebase is in progress
 func GetCurrentBranch() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
 	output, err := cmd.Output()
+	if err != nil {
+		// A branch without commits yet has no HEAD to resolve
+		if unborn, symErr := exec.Command("git", "symbolic-ref", "--short", "HEAD").Output(); symErr == nil {
+			return strings.TrimSpace(string(unborn)), nil
+		}
+		return "", err
+	}
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		if rebaseBranch, ok := getRebaseBranch(); ok {
+			return rebaseBranch, nil
+		}
+	}
+	return branch, nil
+}
+
+// getRebaseBranch returns the branch of an in-progress rebase
+func getRebaseBranch() (string, bool) {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		headName, err := GetGitPath(dir + "/head-name")
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(headName)
+		if err != nil {
+			continue
+		}
+		ref := strings.TrimSpace(string(data))
+		if strings.HasPrefix(ref, "refs/heads/") {
+			return strings.TrimPrefix(ref, "refs/heads/"), true
+		}
+	}
+	return "", false
+}
+
+// RebaseInProgress reports whether a rebase is stopped, e.g. on a conflict
+func RebaseInProgress() bool {
+	_, ok := getRebaseBranch()
+	return ok
+}
+
+// MergeInProgress reports whether the commit being made concludes a merge
+func MergeInProgress() bool {
+	mergeHead, err := GetGitPath("MERGE_HEAD")
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(mergeHead)
+	return err == nil
+}
+
+// GetGitPath resolves a path inside the git directory, e.g. "hooks" or "rebase-merge"
+func GetGitPath(name string) (string, error) {
+	output, err := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-path", name).Output()
 	if err != nil {
 		return "", err
 	}
@@ -28,47 +85,188 @@ func RunGitCommand(args ...string) error {
 	return cmd.Run()
 }
 
-// GetRepoFullName checks if the current directory is a git repository
-// and returns the full repository name (e.g., "owner/repo")
-func GetRepoFullName() (string, error) {
-	// Check if it's a git repository
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	if err := cmd.Run(); err != nil {
-		return "", errors.New("not a git repository")
+// GetRepoRoot returns the top-level directory of the current git repository
+func GetRepoRoot() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	// Get the remote origin URL
-	cmd = exec.Command("git", "config", "--get", "remote.origin.url")
-	output, err := cmd.Output()
+// RepoPath returns path, relative to the current directory or absolute, as
+// a slash-separated path relative to the repository root
+func RepoPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		root, err := GetRepoRoot()
+		if err != nil {
+			return "", err
+		}
+		if path, err = filepath.Rel(root, path); err != nil {
+			return "", err
+		}
+	} else {
+		output, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(strings.TrimSpace(string(output)), path)
+	}
+	path = filepath.ToSlash(path)
+	if path == ".." || strings.HasPrefix(path, "../") {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+	if path == "." {
+		return "", nil
+	}
+	return path, nil
+}
+
+// FileInCommit reports whether path, relative to the repository root, is in commit
+func FileInCommit(commit, path string) bool {
+	return exec.Command("git", "cat-file", "-e", commit+":"+path).Run() == nil
+}
+
+// IsPushed reports whether commit is on a branch of remote, as of the last fetch
+func IsPushed(commit, remote string) bool {
+	output, err := exec.Command("git", "for-each-ref", "--count=1", "--contains", commit, "--format=%(refname)", "refs/remotes/"+remote+"/").Output()
+	return err == nil && len(strings.TrimSpace(string(output))) > 0
+}
+
+// RunEditor opens path in the editor configured for git, which honours
+// GIT_EDITOR, core.editor, VISUAL and EDITOR
+func RunEditor(path string) error {
+	output, err := exec.Command("git", "var", "GIT_EDITOR").Output()
 	if err != nil {
-		return "", errors.New("failed to get remote origin URL")
-	}
-
-	url := strings.TrimSpace(string(output))
-	
-	// Parse the URL to extract owner/repo
-	// Handle both SSH (git@github.com:owner/repo.git) and HTTPS (https://github.com/owner/repo.git)
-	var repoFullName string
-	
-	if strings.HasPrefix(url, "git@") {
-		// SSH format: git@github.com:owner/repo.git
-		parts := strings.Split(url, ":")
-		if len(parts) >= 2 {
-			repoFullName = strings.TrimSuffix(parts[1], ".git")
+		return err
+	}
+	editor := strings.TrimSpace(string(output))
+
+	// Run through the shell like git does so editors with arguments work, e.g. "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// GetDefaultBranch gets the default branch for the remote from its local remote-tracking refs
+func GetDefaultBranch(remote string) (string, error) {
+	// Try to get the default branch from remote HEAD
+	cmd := exec.Command("git", "symbolic-ref", fmt.Sprintf("refs/remotes/%s/HEAD", remote))
+	output, err := cmd.Output()
+	if err == nil {
+		// refs/remotes/origin/HEAD -> refs/remotes/origin/main
+		ref := strings.TrimSpace(string(output))
+		// Extract branch name from refs/remotes/origin/main
+		parts := strings.Split(ref, "/")
+		if len(parts) > 0 {
+			return parts[len(parts)-1], nil
 		}
-	} else if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-		// HTTPS format: https://github.com/owner/repo.git
-		parts := strings.Split(url, "/")
-		if len(parts) >= 2 {
-			owner := parts[len(parts)-2]
-			repo := strings.TrimSuffix(parts[len(parts)-1], ".git")
-			repoFullName = owner + "/" + repo
+	}
+
+	// Fallback: try common default branch names
+	commonDefaults := []string{"main", "master"}
+	for _, branch := range commonDefaults {
+		cmd := exec.Command("git", "rev-parse", "--verify", fmt.Sprintf("refs/remotes/%s/%s", remote, branch))
+		if err := cmd.Run(); err == nil {
+			return branch, nil
 		}
 	}
 
-	if repoFullName == "" {
-		return "", errors.New("failed to parse repository name from URL")
+	return "", fmt.Errorf("could not determine default branch for remote '%s'", remote)
+}
+
+// GetRemoteDefaultBranch asks the remote for its current default branch and
+// falls back to the remote-tracking refs when the remote cannot be reached
+func GetRemoteDefaultBranch(remote string) (string, error) {
+	output, err := exec.Command("git", "ls-remote", "--symref", remote, "HEAD").Output()
+	if err == nil {
+		// ref: refs/heads/main	HEAD
+		for _, line := range strings.Split(string(output), "\n") {
+			if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+				branch, _, _ := strings.Cut(ref, "\t")
+				return branch, nil
+			}
+		}
+	}
+	return GetDefaultBranch(remote)
+}
+
+// SetBranchUpstream sets the upstream of branch to remoteBranch on remote,
+// which unlike git branch --set-upstream-to works before the remote branch exists
+func SetBranchUpstream(branch, remote, remoteBranch string) error {
+	if err := exec.Command("git", "config", fmt.Sprintf("branch.%s.remote", branch), remote).Run(); err != nil {
+		return err
+	}
+	return exec.Command("git", "config", fmt.Sprintf("branch.%s.merge", branch), "refs/heads/"+remoteBranch).Run()
+}
+
+// ForkPoint returns the commit where branch forked from upstream, using the
+// reflog of upstream so it finds the old tip of a rewritten upstream
+func ForkPoint(upstream, branch string) (string, error) {
+	output, err := exec.Command("git", "merge-base", "--fork-point", upstream, branch).Output()
+	if err != nil {
+		return "", fmt.Errorf("no fork point of %s on %s", branch, upstream)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CountCommits returns the number of commits in revisionRange, e.g. "a..b"
+func CountCommits(revisionRange string) (int, error) {
+	output, err := exec.Command("git", "rev-list", "--count", revisionRange).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count the commits of %s: %w", revisionRange, err)
 	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// Upstream is the remote branch a local branch tracks
+type Upstream struct {
+	Remote string
+	// Branch is the name of the branch on the remote, which can differ from the local name
+	Branch string
+}
+
+// String returns the upstream as remote/branch
+func (u Upstream) String() string {
+	return u.Remote + "/" + u.Branch
+}
+
+// TrackingRef returns the remote-tracking ref of the upstream
+func (u Upstream) TrackingRef() string {
+	return "refs/remotes/" + u.Remote + "/" + u.Branch
+}
+
+// GetBranchUpstream returns the upstream configured by branch.<name>.remote and
+// branch.<name>.merge. A remote other than the configured one, or a branch
+// without upstream, uses the branch of the same name on that remote, origin
+// by default.
+func GetBranchUpstream(branch, remote string) (Upstream, error) {
+	configuredRemote, _ := GetGitConfig(fmt.Sprintf("branch.%s.remote", branch))
+	merge, _ := GetGitConfig(fmt.Sprintf("branch.%s.merge", branch))
+	if configuredRemote == "." && remote == "" {
+		return Upstream{}, fmt.Errorf("branch %s tracks the local branch %s, use --remote to pick a remote", branch, strings.TrimPrefix(merge, "refs/heads/"))
+	}
+
+	upstream := Upstream{Remote: remote, Branch: strings.TrimPrefix(merge, "refs/heads/")}
+	if upstream.Remote == "" {
+		upstream.Remote = configuredRemote
+	}
+	if upstream.Remote == "" {
+		upstream.Remote = "origin"
+	}
+	// The merge branch is a branch of the configured remote only
+	if upstream.Branch == "" || upstream.Remote != configuredRemote {
+		upstream.Branch = branch
+	}
+	return upstream, nil
+}
 
-	return repoFullName, nil
+// FetchUpstream fetches the upstream branch into its remote-tracking ref, even
+// when the remote has no fetch refspec for it or the branch was force-pushed
+func FetchUpstream(upstream Upstream) error {
+	refspec := fmt.Sprintf("+refs/heads/%s:%s", upstream.Branch, upstream.TrackingRef())
+	return RunGitCommand("fetch", upstream.Remote, refspec)
 }
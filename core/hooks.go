@@ -0,0 +1,173 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SkipHooksEnv is set by zgit commit so the zgit hooks leave its rendered message alone
+const SkipHooksEnv = "ZGIT_SKIP_HOOKS"
+
+// hookMarker identifies hook scripts written by zgit
+const hookMarker = "# installed by zgit"
+
+// chainedHookSuffix is appended to an existing hook that zgit chains to
+const chainedHookSuffix = ".zgit-chained"
+
+// ManagedHooks are the git hooks installed by zgit
+var ManagedHooks = []string{"prepare-commit-msg", "commit-msg"}
+
+// HookStatus describes the state of a single hook
+type HookStatus struct {
+	Name      string
+	Path      string
+	Installed bool
+	// Foreign is true when a hook not written by zgit is in place
+	Foreign bool
+	// Chained is true when zgit calls a previously installed hook first
+	Chained bool
+}
+
+// GetHooksDir returns the hooks directory, honouring core.hooksPath
+func GetHooksDir() (string, error) {
+	return GetGitPath("hooks")
+}
+
+// hookScript returns the script that chains the previous hook and calls zgit
+func hookScript(name, zgitPath string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s, do not edit. Remove with: zgit hooks uninstall
+chained="$(dirname "$0")/%s%s"
+if [ -x "$chained" ]; then
+	"$chained" "$@" || exit $?
+fi
+zgit=%s
+[ -x "$zgit" ] || zgit=zgit
+exec "$zgit" hooks run %s "$@"
+`, hookMarker, name, chainedHookSuffix, shellQuote(zgitPath), name)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isZgitHook reports whether the hook at path was written by zgit
+func isZgitHook(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(data), hookMarker), nil
+}
+
+// InstallHooks writes the zgit hooks, an existing hook is renamed and chained
+func InstallHooks(zgitPath string) error {
+	hooksDir, err := GetHooksDir()
+	if err != nil {
+		return fmt.Errorf("failed to locate hooks directory: %w", err)
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	for _, name := range ManagedHooks {
+		path := filepath.Join(hooksDir, name)
+		ours, err := isZgitHook(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil && !ours {
+			chainedPath := path + chainedHookSuffix
+			if _, err := os.Stat(chainedPath); err == nil {
+				return fmt.Errorf("cannot chain %s, %s already exists", path, chainedPath)
+			}
+			if err := os.Rename(path, chainedPath); err != nil {
+				return fmt.Errorf("failed to chain existing hook %s: %w", path, err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(hookScript(name, zgitPath)), 0755); err != nil {
+			return fmt.Errorf("failed to write hook %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// UninstallHooks removes the zgit hooks and restores chained hooks
+func UninstallHooks() error {
+	hooksDir, err := GetHooksDir()
+	if err != nil {
+		return fmt.Errorf("failed to locate hooks directory: %w", err)
+	}
+
+	for _, name := range ManagedHooks {
+		path := filepath.Join(hooksDir, name)
+		ours, err := isZgitHook(path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && !ours) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove hook %s: %w", path, err)
+		}
+		chainedPath := path + chainedHookSuffix
+		if _, err := os.Stat(chainedPath); err == nil {
+			if err := os.Rename(chainedPath, path); err != nil {
+				return fmt.Errorf("failed to restore hook %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetHooksStatus returns the state of every hook managed by zgit
+func GetHooksStatus() ([]HookStatus, error) {
+	hooksDir, err := GetHooksDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate hooks directory: %w", err)
+	}
+
+	var statuses []HookStatus
+	for _, name := range ManagedHooks {
+		status := HookStatus{Name: name, Path: filepath.Join(hooksDir, name)}
+		ours, err := isZgitHook(status.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		status.Installed = err == nil && ours
+		status.Foreign = err == nil && !ours
+		if _, err := os.Stat(status.Path + chainedHookSuffix); err == nil {
+			status.Chained = true
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CommitMessageFile is a commit message file as passed to the commit hooks
+type CommitMessageFile struct {
+	Path string
+	*CommitMessage
+}
+
+// ReadCommitMessageFile reads the commit message file at path
+func ReadCommitMessageFile(path string) (*CommitMessageFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CommitMessageFile{
+		Path:          path,
+		CommitMessage: ParseCommitMessage(string(data), true),
+	}, nil
+}
+
+// Write saves the commit message file
+func (f *CommitMessageFile) Write() error {
+	return os.WriteFile(f.Path, []byte(f.String()), 0644)
+}
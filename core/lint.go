@@ -0,0 +1,297 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Lint rules
+const (
+	// LintTemplate checks that the subject has the shape of the commit message template
+	LintTemplate = "template"
+	// LintTicket checks that the subject or a trailer has a ticket matching the ticket pattern
+	LintTicket = "ticket"
+	// LintBranchTicket checks that the message has the tickets of the current branch
+	LintBranchTicket = "branch-ticket"
+	// LintSubjectLength checks the subject length
+	LintSubjectLength = "subject-length"
+	// LintImperative checks that the message does not start with a non-imperative word
+	LintImperative = "imperative"
+	// LintTrailingPeriod checks that the subject does not end with a period
+	LintTrailingPeriod = "trailing-period"
+	// LintBodyWrap checks that body lines are wrapped
+	LintBodyWrap = "body-wrap"
+)
+
+// LintRules are all lint rules, every rule is enabled unless lint.rules lists a subset
+var LintRules = []string{LintTemplate, LintTicket, LintBranchTicket, LintSubjectLength, LintImperative, LintTrailingPeriod, LintBodyWrap}
+
+const (
+	defaultSubjectLength = 72
+	defaultBodyWidth     = 72
+)
+
+// defaultNonImperative are the words a message must not start with when lint.non-imperative is not configured
+var defaultNonImperative = []string{
+	"added", "adds", "adding",
+	"changed", "changes", "changing",
+	"created", "creates", "creating",
+	"fixed", "fixes", "fixing",
+	"implemented", "implements", "implementing",
+	"improved", "improves", "improving",
+	"moved", "moves", "moving",
+	"refactored", "refactors", "refactoring",
+	"removed", "removes", "removing",
+	"renamed", "renames", "renaming",
+	"updated", "updates", "updating",
+}
+
+// LintConfig configures the rules of zgit lint
+type LintConfig struct {
+	// Rules are the enabled rules, all rules when empty
+	Rules []string `yaml:"rules,omitempty" json:"rules,omitempty"`
+	// SubjectLength is the maximum subject length, 72 by default
+	SubjectLength int `yaml:"subject-length,omitempty" json:"subject-length,omitempty"`
+	// BodyWidth is the maximum length of a body line, 72 by default
+	BodyWidth int `yaml:"body-width,omitempty" json:"body-width,omitempty"`
+	// NonImperative are the words a message must not start with, e.g. "added"
+	NonImperative []string `yaml:"non-imperative,omitempty" json:"non-imperative,omitempty"`
+	// Enforce lints every message of zgit commit and the commit-msg hook
+	Enforce *bool `yaml:"enforce,omitempty" json:"enforce,omitempty"`
+}
+
+// merge returns a copy of l with the non-empty fields of override applied on top
+func (l LintConfig) merge(override LintConfig) LintConfig {
+	merged := l
+	if len(override.Rules) > 0 {
+		merged.Rules = override.Rules
+	}
+	if override.SubjectLength > 0 {
+		merged.SubjectLength = override.SubjectLength
+	}
+	if override.BodyWidth > 0 {
+		merged.BodyWidth = override.BodyWidth
+	}
+	if len(override.NonImperative) > 0 {
+		merged.NonImperative = override.NonImperative
+	}
+	if override.Enforce != nil {
+		merged.Enforce = override.Enforce
+	}
+	return merged
+}
+
+// IsEnforced reports whether commits are linted when they are made
+func (l LintConfig) IsEnforced() bool {
+	return l.Enforce != nil && *l.Enforce
+}
+
+// enabled reports whether rule is enabled
+func (l LintConfig) enabled(rule string) bool {
+	return len(l.Rules) == 0 || slices.Contains(l.Rules, rule)
+}
+
+// LintProblem is a rule a commit message breaks
+type LintProblem struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// LintResult are the problems of a single commit message
+type LintResult struct {
+	// Commit is empty for a message that is not committed yet
+	Commit   string        `json:"commit,omitempty"`
+	Subject  string        `json:"subject"`
+	Problems []LintProblem `json:"problems"`
+}
+
+// Lint checks message against the lint rules of the current branch
+func (ctx *CommitContext) Lint(message string) ([]LintProblem, error) {
+	settings := ctx.Match.Settings
+	lint := settings.Lint
+	commitMessage := ParseCommitMessage(message, false)
+	subject := strings.TrimSpace(commitMessage.Subject())
+	problems := []LintProblem{}
+	report := func(rule, format string, args ...any) {
+		problems = append(problems, LintProblem{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	ticketPattern, err := ctx.Config.TicketPattern(settings)
+	if err != nil {
+		return nil, err
+	}
+	shape, err := ctx.templateShape(ticketPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// The message part of the subject, without what the template adds
+	text := subject
+	shaped := false
+	for _, reg := range shape {
+		if match := reg.FindStringSubmatch(subject); match != nil {
+			text, shaped = match[reg.SubexpIndex("message")], true
+			break
+		}
+	}
+	if !shaped && settings.Conventional.IsEnabled() {
+		_, text, _ = settings.Conventional.ParseHeader(text)
+	}
+
+	if lint.enabled(LintTemplate) && len(shape) > 0 && !shaped {
+		report(LintTemplate, "subject does not match the template %s", settings.Commit.Message)
+	}
+	if lint.enabled(LintTicket) && ticketPattern != nil && !ticketPattern.MatchString(message) {
+		report(LintTicket, "message has no ticket matching %s", ticketPattern)
+	}
+	if lint.enabled(LintBranchTicket) {
+		for _, ticket := range ctx.Match.Tickets {
+			if !strings.Contains(message, ticket) {
+				report(LintBranchTicket, "message does not reference ticket %s of branch %s", ticket, ctx.Branch)
+			}
+		}
+	}
+	subjectLength := lint.SubjectLength
+	if subjectLength <= 0 {
+		subjectLength = defaultSubjectLength
+	}
+	if lint.enabled(LintSubjectLength) && utf8.RuneCountInString(subject) > subjectLength {
+		report(LintSubjectLength, "subject is %d characters long, the limit is %d", utf8.RuneCountInString(subject), subjectLength)
+	}
+	nonImperative := lint.NonImperative
+	if len(nonImperative) == 0 {
+		nonImperative = defaultNonImperative
+	}
+	if fields := strings.Fields(text); lint.enabled(LintImperative) && len(fields) > 0 {
+		if word := strings.ToLower(fields[0]); slices.Contains(nonImperative, word) {
+			report(LintImperative, "use the imperative mood, %q is not", fields[0])
+		}
+	}
+	// The template may add a suffix such as the ticket after the message
+	if text = strings.TrimSpace(text); lint.enabled(LintTrailingPeriod) && strings.HasSuffix(text, ".") && !strings.HasSuffix(text, "...") {
+		report(LintTrailingPeriod, "subject ends with a period")
+	}
+	bodyWidth := lint.BodyWidth
+	if bodyWidth <= 0 {
+		bodyWidth = defaultBodyWidth
+	}
+	if lint.enabled(LintBodyWrap) {
+		for i, line := range commitMessage.Lines {
+			// Long words such as URLs cannot be wrapped
+			if i == 0 || utf8.RuneCountInString(line) <= bodyWidth || !strings.Contains(strings.TrimSpace(line), " ") {
+				continue
+			}
+			report(LintBodyWrap, "line %d is %d characters long, wrap the body at %d", i+1, utf8.RuneCountInString(line), bodyWidth)
+		}
+	}
+	return problems, nil
+}
+
+// Placeholders rendered into the template to derive its shape, they only
+// contain characters the template functions leave alone
+const (
+	shapeMessage = "\x00\x011\x01\x00"
+	shapeTicket  = "\x00\x012\x01\x00"
+	shapeType    = "\x00\x013\x01\x00"
+	shapeScope   = "\x00\x014\x01\x00"
+)
+
+// templateShape returns regexes matching subjects rendered by the template,
+// the "message" group is the message. The template is rendered with one to three
+// tickets and, for conventional commits, with and without scope and breaking
+// marker. It returns nil if the template transforms the message.
+func (ctx *CommitContext) templateShape(ticketPattern *regexp.Regexp) ([]*regexp.Regexp, error) {
+	settings := ctx.Match.Settings
+	ticketRegex := `\S+`
+	if ticketPattern != nil {
+		ticketRegex = ticketPattern.String()
+	}
+	typeRegex := strings.Join(quoteAll(settings.Conventional.AllowedTypes()), "|")
+	replacer := strings.NewReplacer(
+		regexp.QuoteMeta(shapeMessage), "(?P<message>.+)",
+		regexp.QuoteMeta(shapeTicket), "(?:"+ticketRegex+")",
+		regexp.QuoteMeta(shapeType), "(?:"+typeRegex+")",
+		regexp.QuoteMeta(shapeScope), `[^()\s]+`,
+	)
+
+	headers := []ConventionalHeader{{}}
+	if settings.Conventional.IsEnabled() {
+		headers = []ConventionalHeader{
+			{Type: shapeType, Scope: shapeScope},
+			{Type: shapeType},
+			{Type: shapeType, Scope: shapeScope, Breaking: true},
+			{Type: shapeType, Breaking: true},
+		}
+	}
+
+	joiner := settings.Commit.Joiner
+	if joiner == "" {
+		joiner = defaultTicketJoiner
+	}
+	var shape []*regexp.Regexp
+	for count := 1; count <= 3; count++ {
+		match := *ctx.Match
+		match.Tickets = nil
+		for range count {
+			match.Tickets = append(match.Tickets, shapeTicket)
+		}
+		match.Ticket = strings.Join(match.Tickets, joiner)
+
+		for _, header := range headers {
+			variant := &CommitContext{Config: ctx.Config, Repo: ctx.Repo, Branch: ctx.Branch, Match: &match, Header: header}
+			rendered, err := variant.Render(shapeMessage)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render commit message template: %w", err)
+			}
+			if !strings.Contains(rendered, shapeMessage) {
+				return nil, nil
+			}
+			reg, err := regexp.Compile("^" + replacer.Replace(regexp.QuoteMeta(strings.TrimSpace(rendered))) + "$")
+			if err != nil {
+				return nil, err
+			}
+			shape = append(shape, reg)
+		}
+	}
+	return shape, nil
+}
+
+// quoteAll quotes every string for use in a regex
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return quoted
+}
+
+// LintRange lints the messages of the non-merge commits in revisionRange, newest first
+func (ctx *CommitContext) LintRange(revisionRange ...string) ([]LintResult, error) {
+	args := append([]string{"log", "--no-merges", "-z", "--format=%H%n%B"}, revisionRange...)
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s: %w", strings.Join(revisionRange, " "), err)
+	}
+
+	var results []LintResult
+	for _, entry := range strings.Split(string(output), "\x00") {
+		hash, message, found := strings.Cut(entry, "\n")
+		if !found {
+			continue
+		}
+		problems, err := ctx.Lint(message)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, LintResult{
+			Commit:   hash,
+			Subject:  ParseCommitMessage(message, false).Subject(),
+			Problems: problems,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// pairConfigKey is the repository git config key holding the co-author aliases of the active pair
+const pairConfigKey = "zgit.pair"
+
+// GetPair returns the co-author aliases of the active pair, nil when nobody is pairing
+func GetPair() []string {
+	value, err := GetGitConfig(pairConfigKey)
+	if err != nil || value == "" {
+		return nil
+	}
+	var aliases []string
+	for _, alias := range strings.Split(value, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// SetPair stores the co-author aliases of the active pair in the repository git config
+func SetPair(aliases []string) error {
+	value := strings.Join(aliases, ",")
+	if output, err := exec.Command("git", "config", "--local", pairConfigKey, value).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s: %w: %s", pairConfigKey, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ClearPair removes the active pair from the repository git config
+func ClearPair() error {
+	if GetPair() == nil {
+		return nil
+	}
+	if output, err := exec.Command("git", "config", "--local", "--unset", pairConfigKey).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unset %s: %w: %s", pairConfigKey, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
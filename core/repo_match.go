@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexRepoPrefix marks a repository name as a regular expression
+const regexRepoPrefix = "regex:"
+
+// RepoMatchKind describes how a repository entry name matched the repository
+type RepoMatchKind int
+
+const (
+	RepoMatchNone RepoMatchKind = iota
+	RepoMatchRegex
+	RepoMatchGlob
+	RepoMatchExact
+)
+
+func (k RepoMatchKind) String() string {
+	switch k {
+	case RepoMatchExact:
+		return "exact"
+	case RepoMatchGlob:
+		return "glob"
+	case RepoMatchRegex:
+		return "regex"
+	default:
+		return "none"
+	}
+}
+
+// repoNameRegex returns the anchored regex of a "regex:" repository name,
+// which must match the whole name like globs do
+func repoNameRegex(name string) string {
+	return "^(?:" + strings.TrimPrefix(name, regexRepoPrefix) + ")$"
+}
+
+// isGlobPattern reports whether name contains glob meta characters
+func isGlobPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// validateRepoName checks that a glob or regex repository name compiles
+func validateRepoName(name string) error {
+	if strings.HasPrefix(name, regexRepoPrefix) {
+		if _, err := regexp.Compile(repoNameRegex(name)); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	} else if isGlobPattern(name) {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid glob: %w", err)
+		}
+	}
+	return nil
+}
+
+// matchRepoName matches repoName against the name of a repository entry,
+// which can be an exact name, a glob such as "myorg/svc-*" or a regex
+// prefixed with "regex:", both must match the whole name. The returned score
+// ranks matches of the same kind, a higher score is more specific.
+func (c *Config) matchRepoName(name, repoName string) (RepoMatchKind, int) {
+	switch {
+	case strings.HasPrefix(name, regexRepoPrefix):
+		reg, err := c.regexp(repoNameRegex(name))
+		if err != nil || !reg.MatchString(repoName) {
+			return RepoMatchNone, 0
+		}
+		return RepoMatchRegex, len(name) - len(regexRepoPrefix)
+	case isGlobPattern(name):
+		matched, err := path.Match(name, repoName)
+		if err != nil || !matched {
+			return RepoMatchNone, 0
+		}
+		// more literal characters means a more specific glob
+		return RepoMatchGlob, len(name) - strings.Count(name, "*") - strings.Count(name, "?")
+	case name == repoName:
+		return RepoMatchExact, len(name)
+	default:
+		return RepoMatchNone, 0
+	}
+}
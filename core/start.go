@@ -0,0 +1,137 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultBranchTemplate is used when start.branch is not configured
+const defaultBranchTemplate = "usr/{{.User.Login}}/{{.Ticket}}{{with .Slug}}-{{.}}{{end}}"
+
+// maxSlugLength limits the slug of the description in branch names
+const maxSlugLength = 40
+
+// StartConfig configures the branches created by zgit start
+type StartConfig struct {
+	// Branch is the branch name template, e.g. usr/{{.User.Login}}/{{.Ticket}}-{{.Slug}}
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+}
+
+// merge returns a copy of s with the non-empty fields of override applied on top
+func (s StartConfig) merge(override StartConfig) StartConfig {
+	merged := s
+	if override.Branch != "" {
+		merged.Branch = override.Branch
+	}
+	return merged
+}
+
+// BranchTemplate returns the configured branch name template or the default one
+func (s StartConfig) BranchTemplate() string {
+	if s.Branch != "" {
+		return s.Branch
+	}
+	return defaultBranchTemplate
+}
+
+// BranchData is the data available in the branch name template
+type BranchData struct {
+	Ticket      string
+	Description string
+	// Slug is the description in lower case with words joined by "-"
+	Slug string
+	User BranchUser
+	Date time.Time
+}
+
+// BranchUser is the git user in the branch name template
+type BranchUser struct {
+	Name  string
+	Email string
+	// Login is git config zgit.user, or the part of the email before the @
+	Login string
+}
+
+// NewBranchData collects the template data for a branch of ticket
+func NewBranchData(ticket, description string) *BranchData {
+	data := &BranchData{
+		Ticket:      ticket,
+		Description: description,
+		Slug:        Slugify(description, maxSlugLength),
+		Date:        time.Now(),
+	}
+	data.User.Name, _ = GetGitConfig("user.name")
+	data.User.Email, _ = GetGitConfig("user.email")
+	data.User.Login, _ = GetGitConfig("zgit.user")
+	if data.User.Login == "" {
+		login, _, _ := strings.Cut(data.User.Email, "@")
+		data.User.Login = Slugify(login, maxSlugLength)
+	}
+	return data
+}
+
+// Slugify lower-cases s and joins its words with "-", cutting it at a word
+// boundary so it has at most maxLength characters
+func Slugify(s string, maxLength int) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slug := ""
+	for _, word := range words {
+		next := word
+		if slug != "" {
+			next = slug + "-" + word
+		}
+		if utf8.RuneCountInString(next) > maxLength {
+			if slug == "" {
+				slug = string([]rune(word)[:maxLength])
+			}
+			break
+		}
+		slug = next
+	}
+	return slug
+}
+
+// RenderBranchName renders the branch name template of settings
+func (c *Config) RenderBranchName(settings GlobalConfig, data *BranchData) (string, error) {
+	tmpl, err := c.CommitTemplate(settings.Start.BranchTemplate())
+	if err != nil {
+		return "", fmt.Errorf("invalid branch name template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render branch name template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// CheckStartBranch verifies that branch is a valid branch name whose ticket is
+// found again by the branch patterns, so commits on it get the ticket
+func (c *Config) CheckStartBranch(repo *RepoIdentity, branch, ticket string) error {
+	if err := exec.Command("git", "check-ref-format", "--branch", branch).Run(); err != nil {
+		return fmt.Errorf("%s is not a valid branch name", branch)
+	}
+	match, err := c.MatchBranch(repo, branch)
+	if errors.Is(err, ErrTicketNotFound) {
+		return fmt.Errorf("no branch pattern finds a ticket in %s", branch)
+	} else if err != nil {
+		return err
+	}
+	if !slices.Contains(match.Tickets, ticket) {
+		return fmt.Errorf("branch pattern %s finds ticket %s in %s instead of %s", match.Pattern, match.Ticket, branch, ticket)
+	}
+	return nil
+}
+
+// BranchExists reports whether the local branch exists
+func BranchExists(branch string) bool {
+	return exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil
+}
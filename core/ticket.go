@@ -0,0 +1,257 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Ticket sources, in the order they are tried
+const (
+	// TicketFromFlag is the ticket given with --ticket
+	TicketFromFlag = "flag"
+	// TicketFromBranch is the ticket extracted from the branch name
+	TicketFromBranch = "branch"
+	// TicketFromGitConfig is the ticket stored in git config branch.<name>.zgit-ticket
+	TicketFromGitConfig = "git-config"
+	// TicketFromLastCommit is the ticket found in the last commit message of the branch
+	TicketFromLastCommit = "last-commit"
+	// TicketFromPrompt is the ticket typed by the user
+	TicketFromPrompt = "prompt"
+)
+
+// defaultTicketFallback is used when ticket.fallback is not configured
+var defaultTicketFallback = []string{TicketFromGitConfig}
+
+// TicketConfig configures how the ticket is resolved when the branch name has none
+type TicketConfig struct {
+	// Fallback lists the sources tried in order: git-config, last-commit and prompt
+	Fallback []string `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// Remember stores a ticket that did not come from the branch name in git config
+	Remember *bool `yaml:"remember,omitempty" json:"remember,omitempty"`
+	// Pattern matches a ticket, by default the ticket groups of the branch patterns are used
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+}
+
+// merge returns a copy of t with the non-empty fields of override applied on top
+func (t TicketConfig) merge(override TicketConfig) TicketConfig {
+	merged := t
+	if len(override.Fallback) > 0 {
+		merged.Fallback = override.Fallback
+	}
+	if override.Remember != nil {
+		merged.Remember = override.Remember
+	}
+	if override.Pattern != "" {
+		merged.Pattern = override.Pattern
+	}
+	return merged
+}
+
+// isTicketFallback reports whether source can be used in ticket.fallback
+func isTicketFallback(source string) bool {
+	switch source {
+	case TicketFromGitConfig, TicketFromLastCommit, TicketFromPrompt:
+		return true
+	}
+	return false
+}
+
+// TicketOptions are the command line choices for resolving the ticket
+type TicketOptions struct {
+	// Tickets are given with --ticket and win over every other source
+	Tickets []string
+	// Prompt asks the user for a ticket, nil when zgit runs non-interactively
+	Prompt func(branch string, ticketPattern *regexp.Regexp) ([]string, error)
+}
+
+// ResolveTicket sets the ticket of a match from the flag, or from the
+// configured fallback chain when the branch name has none
+func (c *Config) ResolveTicket(match *BranchMatch, branch string, opts TicketOptions) error {
+	switch {
+	case len(opts.Tickets) > 0:
+		match.setTickets(opts.Tickets, TicketFromFlag)
+	case match.Ticket != "":
+		return nil
+	default:
+		fallback := match.Settings.Ticket.Fallback
+		if len(fallback) == 0 {
+			fallback = defaultTicketFallback
+		}
+		for _, source := range fallback {
+			tickets, err := c.fallbackTickets(source, match, branch, opts)
+			if err != nil {
+				return err
+			}
+			if len(tickets) > 0 {
+				match.setTickets(tickets, source)
+				break
+			}
+		}
+	}
+
+	if match.Ticket == "" {
+		return ErrTicketNotFound
+	}
+	return nil
+}
+
+// RememberTicket stores a ticket that did not come from the branch name in
+// git config when ticket.remember is set, so the next commit finds it. Call it
+// once the commit is made, an aborted commit must not change the ticket.
+func RememberTicket(match *BranchMatch, branch string) error {
+	remember := match.Settings.Ticket.Remember
+	if remember == nil || !*remember {
+		return nil
+	}
+	switch match.TicketSource {
+	case TicketFromFlag, TicketFromLastCommit, TicketFromPrompt:
+	default:
+		return nil
+	}
+	if err := SetBranchTickets(branch, match.Tickets); err != nil {
+		return fmt.Errorf("failed to remember ticket for branch %s: %w", branch, err)
+	}
+	log.Infof("remembered ticket %s for branch %s", match.Ticket, branch)
+	return nil
+}
+
+// fallbackTickets returns the tickets from a single fallback source, or nil if it has none
+func (c *Config) fallbackTickets(source string, match *BranchMatch, branch string, opts TicketOptions) ([]string, error) {
+	switch source {
+	case TicketFromGitConfig:
+		return GetBranchTickets(branch), nil
+	case TicketFromLastCommit:
+		ticketPattern, err := c.TicketPattern(match.Settings)
+		if err != nil || ticketPattern == nil {
+			return nil, err
+		}
+		message, err := GetCommitMessage("HEAD")
+		if err != nil {
+			// A branch without commits has no last commit
+			return nil, nil
+		}
+		var tickets []string
+		for _, ticket := range ticketPattern.FindAllString(message, -1) {
+			tickets = appendTicket(tickets, ticket)
+		}
+		return tickets, nil
+	case TicketFromPrompt:
+		if opts.Prompt == nil {
+			return nil, nil
+		}
+		ticketPattern, err := c.TicketPattern(match.Settings)
+		if err != nil {
+			return nil, err
+		}
+		return opts.Prompt(branch, ticketPattern)
+	}
+	return nil, fmt.Errorf("unknown ticket fallback %s", source)
+}
+
+// setTickets sets the tickets of the match and joins them into Ticket
+func (m *BranchMatch) setTickets(tickets []string, source string) {
+	m.Tickets = nil
+	for _, ticket := range tickets {
+		m.Tickets = appendTicket(m.Tickets, ticket)
+	}
+	joiner := m.Settings.Commit.Joiner
+	if joiner == "" {
+		joiner = defaultTicketJoiner
+	}
+	m.Ticket = strings.Join(m.Tickets, joiner)
+	m.TicketSource = source
+	if m.Groups == nil {
+		m.Groups = map[string]string{}
+	}
+	m.Groups["ticket"] = m.Ticket
+}
+
+// appendTicket appends ticket to tickets unless it is empty or already present
+func appendTicket(tickets []string, ticket string) []string {
+	if ticket == "" || slices.Contains(tickets, ticket) {
+		return tickets
+	}
+	return append(tickets, ticket)
+}
+
+// SplitTickets splits a list of tickets separated by commas or spaces,
+// as given to --ticket or stored in git config
+func SplitTickets(value string) []string {
+	var tickets []string
+	for _, ticket := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		tickets = appendTicket(tickets, ticket)
+	}
+	return tickets
+}
+
+// TicketPattern returns the regex matching a ticket: ticket.pattern, or the
+// ticket groups of the branch patterns combined. It returns nil if there is none.
+func (c *Config) TicketPattern(settings GlobalConfig) (*regexp.Regexp, error) {
+	if settings.Ticket.Pattern != "" {
+		return c.regexp(settings.Ticket.Pattern)
+	}
+
+	var alternatives []string
+	for _, branchPattern := range settings.Branches {
+		if group := ticketGroupPattern(branchPattern); group != "" {
+			alternatives = append(alternatives, group)
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return c.regexp(`\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+// ticketGroupPattern returns the sub pattern of the ticket group of a branch pattern
+func ticketGroupPattern(branchPattern string) string {
+	re, err := syntax.Parse(branchPattern, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	var find func(re *syntax.Regexp) string
+	find = func(re *syntax.Regexp) string {
+		if re.Op == syntax.OpCapture && re.Name == "ticket" && len(re.Sub) == 1 {
+			return re.Sub[0].String()
+		}
+		for _, sub := range re.Sub {
+			if pattern := find(sub); pattern != "" {
+				return pattern
+			}
+		}
+		return ""
+	}
+	return find(re)
+}
+
+// branchTicketKey is the git config key remembering the ticket of a branch
+func branchTicketKey(branch string) string {
+	return fmt.Sprintf("branch.%s.zgit-ticket", branch)
+}
+
+// GetBranchTickets returns the tickets remembered for branch
+func GetBranchTickets(branch string) []string {
+	value, err := GetGitConfig(branchTicketKey(branch))
+	if err != nil {
+		return nil
+	}
+	return SplitTickets(value)
+}
+
+// SetBranchTickets remembers the tickets of branch in the repository git config
+func SetBranchTickets(branch string, tickets []string) error {
+	if branch == "" || branch == "HEAD" {
+		return errors.New("cannot remember a ticket on a detached HEAD")
+	}
+	return exec.Command("git", "config", branchTicketKey(branch), strings.Join(tickets, ",")).Run()
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// CoAuthorTrailer is the trailer key GitHub and GitLab use for co-authors
+const CoAuthorTrailer = "Co-authored-by"
+
+// TrailerConfig is a trailer added to every commit message, the value is a
+// template with the same data as the commit message template
+type TrailerConfig struct {
+	Key   string `yaml:"key" json:"key"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// CoAuthor is a person that can be credited with Co-authored-by by alias
+type CoAuthor struct {
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email" json:"email"`
+}
+
+// String returns the co-author in the "Name <email>" trailer form
+func (a CoAuthor) String() string {
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
+
+// Trailer is a rendered git trailer
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// ResolveCoAuthors returns the co-authors of aliases, an alias can also be
+// given in the "Name <email>" form. A co-author named twice is returned once.
+func (g GlobalConfig) ResolveCoAuthors(aliases []string) ([]CoAuthor, error) {
+	var coAuthors []CoAuthor
+	for _, alias := range aliases {
+		coAuthor, err := g.resolveCoAuthor(strings.TrimSpace(alias))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(coAuthors, func(a CoAuthor) bool { return strings.EqualFold(a.Email, coAuthor.Email) }) {
+			coAuthors = append(coAuthors, coAuthor)
+		}
+	}
+	return coAuthors, nil
+}
+
+// resolveCoAuthor returns the co-author of an alias or of a "Name <email>" value
+func (g GlobalConfig) resolveCoAuthor(alias string) (CoAuthor, error) {
+	if coAuthor, ok := g.CoAuthors[alias]; ok {
+		return coAuthor, nil
+	}
+	name, email, found := strings.Cut(alias, "<")
+	if !found || !strings.HasSuffix(email, ">") {
+		return CoAuthor{}, fmt.Errorf("unknown co-author alias %s", alias)
+	}
+	return CoAuthor{Name: strings.TrimSpace(name), Email: strings.TrimSuffix(email, ">")}, nil
+}
+
+// Trailers renders the configured trailers and the co-authors of the commit.
+// A value rendering to several lines adds a trailer per line, empty values are skipped.
+func (ctx *CommitContext) Trailers() ([]Trailer, error) {
+	var trailers []Trailer
+	data := NewCommitData(ctx.Match, ctx.Repo, ctx.Branch, "")
+	data.Type, data.Scope, data.Breaking = ctx.Header.Type, ctx.Header.Scope, ctx.Header.Breaking
+	for _, trailerConfig := range ctx.Match.Settings.Commit.Trailers {
+		value, err := ctx.Config.RenderCommitMessage(trailerConfig.Value, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render trailer %s: %w", trailerConfig.Key, err)
+		}
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				trailers = append(trailers, Trailer{Key: trailerConfig.Key, Value: line})
+			}
+		}
+	}
+	for _, coAuthor := range ctx.CoAuthors {
+		trailers = append(trailers, Trailer{Key: CoAuthorTrailer, Value: coAuthor.String()})
+	}
+	return trailers, nil
+}
+
+// AddTrailers appends trailers to message with git interpret-trailers, a
+// trailer already in the message with the same value is not added again
+func AddTrailers(message string, trailers []Trailer) (string, error) {
+	if len(trailers) == 0 {
+		return message, nil
+	}
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent"}
+	for _, trailer := range trailers {
+		args = append(args, "--trailer", trailer.Key+": "+trailer.Value)
+	}
+	// Without the final newline git takes the subject for the trailer block
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = strings.NewReader(message)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to add trailers: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// ApplyTrailers adds the configured trailers and the co-authors to message
+func (ctx *CommitContext) ApplyTrailers(message *CommitMessage) error {
+	trailers, err := ctx.Trailers()
+	if err != nil || len(trailers) == 0 {
+		return err
+	}
+	text, err := AddTrailers(message.String(), trailers)
+	if err != nil {
+		return err
+	}
+	message.Lines = strings.Split(text, "\n")
+	return nil
+}
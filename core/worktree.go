@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a working tree of the repository, the main one or a linked one
+type Worktree struct {
+	Path string
+	Head string
+	// Branch is empty when HEAD is detached
+	Branch string
+	// Main is the worktree of the repository itself, it cannot be removed
+	Main   bool
+	Locked bool
+	// Prunable worktrees have lost their directory
+	Prunable bool
+}
+
+// ListWorktrees returns the worktrees of the repository, the main one first
+func ListWorktrees() ([]Worktree, error) {
+	output, err := exec.Command("git", "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	var worktrees []Worktree
+	// Each worktree is a block of "key value" lines, blocks are separated by an empty line
+	for _, block := range strings.Split(strings.TrimSpace(string(output)), "\n\n") {
+		var worktree Worktree
+		for _, line := range strings.Split(block, "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				worktree.Path = value
+			case "HEAD":
+				worktree.Head = value
+			case "branch":
+				worktree.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "locked":
+				worktree.Locked = true
+			case "prunable":
+				worktree.Prunable = true
+			}
+		}
+		if worktree.Path == "" {
+			continue
+		}
+		worktree.Main = len(worktrees) == 0
+		worktrees = append(worktrees, worktree)
+	}
+	return worktrees, nil
+}
+
+// IsLinkedWorktree reports whether the current directory is in a linked
+// worktree rather than in the main one
+func IsLinkedWorktree() bool {
+	output, err := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-dir", "--git-common-dir").Output()
+	if err != nil {
+		return false
+	}
+	dirs := strings.Fields(string(output))
+	return len(dirs) == 2 && dirs[0] != dirs[1]
+}
+
+// HasChanges reports whether the worktree has uncommitted changes or untracked files
+func (w Worktree) HasChanges() (bool, error) {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = w.Path
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the status of worktree %s: %w", w.Path, err)
+	}
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+// DefaultWorktreePath returns where the worktree of ticket goes: next to the
+// main worktree, named after it and the ticket, e.g. ../zgit-JIRA-123
+func DefaultWorktreePath(ticket string) (string, error) {
+	worktrees, err := ListWorktrees()
+	if err != nil {
+		return "", err
+	}
+	if len(worktrees) == 0 {
+		return "", fmt.Errorf("no worktree found")
+	}
+	main := worktrees[0].Path
+	return filepath.Join(filepath.Dir(main), filepath.Base(main)+"-"+ticket), nil
+}
+
+// BranchMoved reports whether branch points to another commit than the one
+// it was created at, according to its reflog. Without a reflog it returns true.
+func BranchMoved(branch string) bool {
+	output, err := exec.Command("git", "reflog", "show", "--format=%H", "refs/heads/"+branch, "--").Output()
+	commits := strings.Fields(string(output))
+	if err != nil || len(commits) == 0 {
+		return true
+	}
+	return commits[0] != commits[len(commits)-1]
+}